@@ -0,0 +1,332 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/components"
+	"github.com/danvolchek/bouncer-go/lib/events"
+	"golang.org/x/exp/slices"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// maxMessageLength is the most characters discord allows in a message.
+const maxMessageLength = 2000
+
+// fixedConfigKeys are config fields that can't be changed with the config command, and why.
+var fixedConfigKeys = map[string]string{
+	"discord":      "the token can only be changed in the config file",
+	"discord_file": "the token can only be changed in the config file",
+	"dry_run":      "dry run mode can't be changed without restarting",
+	"servers.home": "the home server can only be changed in the config file",
+}
+
+type config struct{}
+
+func (c *config) Setup(_ *lib.Utils) {}
+
+func (c *config) Name() string {
+	return "config"
+}
+
+func (c *config) Description() string {
+	return "View or change config settings"
+}
+
+func (c *config) Arguments() []components.Argument {
+	return []components.Argument{
+		{Name: "action", Description: "What to do", Type: components.ArgumentString, Required: true, Choices: []string{"list", "get", "set", "reset"}},
+		{Name: "key", Description: "The setting, e.g. DM.ban", Type: components.ArgumentString, Autocomplete: c.keys},
+		{Name: "value", Description: "The new value - lists are comma separated", Type: components.ArgumentText},
+	}
+}
+
+func (c *config) RequiresUser() bool {
+	return false
+}
+
+func (c *config) Permission() components.Permission {
+	return components.PermissionOwner
+}
+
+// Ephemeral keeps config values out of the channel when run as a slash command.
+func (c *config) Ephemeral() bool {
+	return true
+}
+
+func (c *config) Handle(ctx context.Context, command *components.CommandDetails, message *discordgo.Message, utils *lib.Utils) error {
+	usage := components.BadInput("Usage: `%[1]sconfig list`, `%[1]sconfig get <key>`, `%[1]sconfig set <key> <value>` or `%[1]sconfig reset <key>`", utils.Config().Prefix)
+
+	if len(command.Args) == 0 {
+		return usage
+	}
+
+	action := command.Args[0]
+	if action == "list" {
+		return c.list(ctx, message, utils)
+	}
+
+	if len(command.Args) < 2 {
+		return usage
+	}
+
+	key, ok := configKey(command.Args[1])
+	if !ok {
+		return components.NotFound("There's no setting `%s` - see `%sconfig list`", command.Args[1], utils.Config().Prefix)
+	}
+
+	switch action {
+	case "get":
+		return c.get(ctx, key, message, utils)
+	case "set":
+		if len(command.Args) < 3 {
+			return usage
+		}
+		return c.set(ctx, key, command.RawFrom(2), message, utils)
+	case "reset":
+		return c.reset(ctx, key, message, utils)
+	default:
+		return usage
+	}
+}
+
+// Confirmation asks before resetting a setting, since the value set with this command is lost.
+func (c *config) Confirmation(ctx context.Context, command *components.CommandDetails, utils *lib.Utils) (string, bool) {
+	if len(command.Args) < 2 || command.Args[0] != "reset" {
+		return "", false
+	}
+
+	key, ok := configKey(command.Args[1])
+	if !ok {
+		return "", false
+	}
+
+	// if there's nothing to reset, Handle says so
+	override, err := utils.Repo().ConfigOverride(ctx, key)
+	if err != nil {
+		return "", false
+	}
+
+	return fmt.Sprintf("Reset `%s` to the config file's value? It's set to %s with this command.", key, formatConfigValue(override.Value)), true
+}
+
+// list replies with every setting's current value.
+func (c *config) list(ctx context.Context, message *discordgo.Message, utils *lib.Utils) error {
+	overrides, err := utils.Repo().ConfigOverrides(ctx)
+	if err != nil {
+		return err
+	}
+
+	var reply strings.Builder
+	reply.WriteString("Settings (* means set with this command):\n")
+	for _, key := range lib.ConfigPaths() {
+		if lib.IsSecretConfig(key) {
+			continue
+		}
+
+		value, err := utils.Config().Get(key)
+		if err != nil {
+			return err
+		}
+
+		marker := ""
+		if slices.ContainsFunc(overrides, func(override database.ConfigOverride) bool { return override.Key == key }) {
+			marker = " *"
+		}
+
+		reply.WriteString(fmt.Sprintf("`%s`: %s%s\n", key, formatConfigValue(value), marker))
+	}
+
+	// the settings may not fit in one message
+	for _, text := range splitMessage(reply.String()) {
+		utils.Reply(message, text)
+	}
+	return nil
+}
+
+// get replies with a setting's current value, and who last changed it.
+func (c *config) get(ctx context.Context, key string, message *discordgo.Message, utils *lib.Utils) error {
+	if lib.IsSecretConfig(key) {
+		return components.PermissionDenied("`%s` is secret", key)
+	}
+
+	value, err := utils.Config().Get(key)
+	if err != nil {
+		return err
+	}
+
+	reply := fmt.Sprintf("`%s` is %s", key, formatConfigValue(value))
+
+	change, err := utils.Repo().LastConfigChange(ctx, key)
+	switch {
+	case err == nil:
+		reply += fmt.Sprintf(" - last %s by %s <t:%d:R>", change.Action, change.Staff, change.Date.Unix())
+	case !errors.Is(err, database.ErrNotFound):
+		return err
+	}
+
+	utils.Reply(message, reply)
+	return nil
+}
+
+// set overrides a setting and applies it.
+func (c *config) set(ctx context.Context, key, text string, message *discordgo.Message, utils *lib.Utils) error {
+	if reason, ok := fixedConfigKeys[key]; ok {
+		return components.PermissionDenied("`%s` can't be changed: %s", key, reason)
+	}
+
+	updated := utils.Config().Copy()
+	if err := updated.Set(key, mentionRegexp.ReplaceAllString(text, "$1")); err != nil {
+		return components.BadInput("`%s` can't be set to that: %s", key, err)
+	}
+	if err := updated.Validate(); err != nil {
+		return components.BadInput("`%s` can't be set to that: %s", key, err)
+	}
+
+	value, err := updated.Get(key)
+	if err != nil {
+		return err
+	}
+
+	return c.change(ctx, key, database.ConfigSet, value, message, utils)
+}
+
+// reset removes a setting's override, so it's back to the config file's value.
+func (c *config) reset(ctx context.Context, key string, message *discordgo.Message, utils *lib.Utils) error {
+	_, err := utils.Repo().ConfigOverride(ctx, key)
+	if errors.Is(err, database.ErrNotFound) {
+		return components.NotFound("`%s` hasn't been set with this command", key)
+	}
+	if err != nil {
+		return err
+	}
+
+	return c.change(ctx, key, database.ConfigReset, "", message, utils)
+}
+
+// change records a change to a setting along with it, applies it, and reports it.
+func (c *config) change(ctx context.Context, key string, action database.ConfigChangeKind, value string, message *discordgo.Message, utils *lib.Utils) error {
+	old, err := utils.Config().Get(key)
+	if err != nil {
+		return err
+	}
+
+	// kept so the change can be undone if the config check rejects it
+	previous, err := utils.Repo().ConfigOverride(ctx, key)
+	if err != nil && !errors.Is(err, database.ErrNotFound) {
+		return err
+	}
+
+	change := &database.ConfigChange{
+		Key:     key,
+		Action:  action,
+		Old:     old,
+		New:     value,
+		StaffId: message.Author.ID,
+		Staff:   message.Author.Username,
+		Date:    time.Now(),
+	}
+
+	if err = utils.Repo().ChangeConfig(ctx, change); err != nil {
+		return err
+	}
+
+	// overrides are applied when the config is checked, so the change has to be saved before it can be checked
+	if err = utils.RefreshConfig(); err != nil {
+		if undoErr := utils.Repo().UndoConfigChange(ctx, change, previous); undoErr != nil {
+			return fmt.Errorf("config change couldn't be applied, and undoing it failed: %w", errors.Join(err, undoErr))
+		}
+
+		return components.BadInput("`%s` can't be %s: %s", key, action, err)
+	}
+
+	// the checked value, which is the file's value after a reset, or may differ if the config check changed it
+	requested := value
+	value, err = utils.Config().Get(key)
+	if err != nil {
+		return err
+	}
+
+	utils.Log.Info().Str("key", key).Str("old", old).Str("new", value).Msgf("Config %s", action)
+	lib.Publish(ctx, utils, events.ConfigChanged{
+		Staff: message.Author,
+		Key:   key,
+		Old:   old,
+		New:   value,
+		Reset: action == database.ConfigReset,
+	})
+
+	reply := fmt.Sprintf("`%s` is now %s (was %s)", key, formatConfigValue(value), formatConfigValue(old))
+	if action == database.ConfigSet && value != requested {
+		reply += fmt.Sprintf(". It was set to %s, but the config check changed it - see the logs", formatConfigValue(requested))
+	}
+
+	utils.Reply(message, reply)
+	return nil
+}
+
+// keys suggests setting keys.
+func (c *config) keys(_ *lib.Utils, partial string) []string {
+	var keys []string
+	for _, key := range lib.ConfigPaths() {
+		if strings.HasPrefix(strings.ToLower(key), strings.ToLower(partial)) && !lib.IsSecretConfig(key) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// configKey returns the setting key matching arg. Keys are matched case-insensitively, since the DM section is
+// capitalized.
+func configKey(arg string) (string, bool) {
+	i := slices.IndexFunc(lib.ConfigPaths(), func(path string) bool { return strings.EqualFold(path, arg) })
+	if i == -1 {
+		return "", false
+	}
+
+	return lib.ConfigPaths()[i], true
+}
+
+// mentionRegexp matches channel, role and user mentions, capturing their id.
+var mentionRegexp = regexp.MustCompile(`<(?:#|@&|@!?)(\d+)>`)
+
+// splitMessage splits text into messages short enough to send, between lines where possible. Lines too long for one
+// message are cut wherever they reach the limit.
+func splitMessage(text string) []string {
+	var messages []string
+	var current []rune
+	for _, line := range strings.SplitAfter(text, "\n") {
+		runes := []rune(line)
+		if len(current)+len(runes) > maxMessageLength && len(current) > 0 {
+			messages = append(messages, string(current))
+			current = nil
+		}
+
+		for len(runes) > maxMessageLength {
+			messages = append(messages, string(runes[:maxMessageLength]))
+			runes = runes[maxMessageLength:]
+		}
+		current = append(current, runes...)
+	}
+
+	if len(current) > 0 {
+		messages = append(messages, string(current))
+	}
+
+	return messages
+}
+
+// formatConfigValue formats a setting's value for a reply.
+func formatConfigValue(value string) string {
+	if value == "" {
+		return "not set"
+	}
+
+	return "`" + value + "`"
+}
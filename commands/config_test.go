@@ -0,0 +1,239 @@
+package commands_test
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/harness"
+	"github.com/danvolchek/bouncer-go/lib"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// lastMessage waits for the bot's last message in the commands channel to match, and returns it.
+func lastMessage(t *testing.T, h *harness.Harness, matches func(message *discordgo.Message) bool) *discordgo.Message {
+	t.Helper()
+
+	var found *discordgo.Message
+	ok := h.WaitFor(func() bool {
+		messages := h.Discord.Messages(h.Channels.Commands.ID)
+		if len(messages) == 0 {
+			return false
+		}
+
+		found = messages[len(messages)-1]
+		return found.Author.ID == h.Discord.BotUser().ID && matches(found)
+	})
+	if !ok {
+		t.Fatalf("no matching message, last was %q", found.Content)
+	}
+
+	return found
+}
+
+func hasButtons(message *discordgo.Message) bool {
+	return len(message.Components) != 0
+}
+
+func overrides(t *testing.T, h *harness.Harness) []database.ConfigOverride {
+	t.Helper()
+
+	var rows []database.ConfigOverride
+	if err := h.DB.Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return rows
+}
+
+func TestConfigResetConfirmation(t *testing.T) {
+	tests := []struct {
+		label     string
+		overrides int
+		prefix    string
+	}{
+		{"Confirm", 0, "$"},
+		{"Cancel", 1, "!"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.label, func(t *testing.T) {
+			h, err := harness.New(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close()
+
+			h.Say(h.Owner, "$config set command_prefix !")
+			if len(overrides(t, h)) != 1 {
+				t.Fatal("prefix wasn't overridden")
+			}
+
+			wait := h.SayAsync(h.Owner, "!config reset command_prefix")
+			prompt := lastMessage(t, h, hasButtons)
+			if !strings.Contains(prompt.Content, "Reset `command_prefix`") {
+				t.Errorf("prompt = %q", prompt.Content)
+			}
+
+			if err = h.Click(h.Owner, prompt, test.label); err != nil {
+				t.Fatal(err)
+			}
+			wait()
+
+			if got := len(overrides(t, h)); got != test.overrides {
+				t.Errorf("%d overrides, want %d", got, test.overrides)
+			}
+
+			if got := h.Bot.Config().Prefix; got != test.prefix {
+				t.Errorf("prefix = %q, want %q", got, test.prefix)
+			}
+		})
+	}
+}
+
+func TestConfigResetNothingNoConfirmation(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Say(h.Owner, "$config reset command_prefix")
+
+	if reply := h.LastReply(); reply != "`command_prefix` hasn't been set with this command." {
+		t.Errorf("reply = %q", reply)
+	}
+}
+
+func TestConfigResetConfirmationSlash(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Say(h.Owner, "$config set command_prefix !")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := h.Slash(h.Owner, "config",
+			&discordgo.ApplicationCommandInteractionDataOption{Name: "action", Type: discordgo.ApplicationCommandOptionString, Value: "reset"},
+			&discordgo.ApplicationCommandInteractionDataOption{Name: "key", Type: discordgo.ApplicationCommandOptionString, Value: "command_prefix"},
+		)
+		if err != nil {
+			panic(err)
+		}
+	}()
+
+	// the prompt replaces the deferred response, so it's only visible to the owner like the rest of the replies
+	prompt := lastMessage(t, h, hasButtons)
+	if prompt.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Errorf("prompt %q isn't ephemeral", prompt.Content)
+	}
+
+	if err = h.Click(h.Owner, prompt, "Confirm"); err != nil {
+		t.Fatal(err)
+	}
+	<-done
+
+	reply := lastMessage(t, h, func(message *discordgo.Message) bool { return strings.Contains(message.Content, "is now") })
+	if reply.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Errorf("reply %q isn't ephemeral", reply.Content)
+	}
+
+	if got := h.Bot.Config().Prefix; got != "$" {
+		t.Errorf("prefix = %q, want $", got)
+	}
+}
+
+func TestConfigChangeLogged(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Say(h.Owner, "$config set command_prefix !")
+
+	posts := h.Posts(h.Channels.Log.ID)
+	if want := "owner set config `command_prefix` from `$` to `!`"; len(posts) != 1 || posts[0] != want {
+		t.Errorf("log posts = %q, want %q", posts, want)
+	}
+}
+
+func TestConfigList(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// enough owners that the settings don't fit in one message
+	config := h.Bot.Config().Copy()
+	for i := 0; i < 150; i++ {
+		config.Users.Owners = append(config.Users.Owners, fmt.Sprintf("1000000000000000%d", i))
+	}
+	if err = h.Bot.ReloadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Say(h.Owner, "$config list")
+
+	replies := h.Replies()
+	if len(replies) < 2 {
+		t.Errorf("%d replies, want the settings split over several", len(replies))
+	}
+
+	for _, reply := range replies {
+		if n := utf8.RuneCountInString(reply); n > 2000 {
+			t.Errorf("reply is %d characters, more than discord allows", n)
+		}
+	}
+
+	all := strings.Join(replies, "")
+	for _, key := range lib.ConfigPaths() {
+		if shown := strings.Contains(all, "`"+key+"`:"); shown == lib.IsSecretConfig(key) {
+			t.Errorf("%s shown = %t", key, shown)
+		}
+	}
+}
+
+func TestConfigSetRejectedIsUndone(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Say(h.Owner, "$config set command_prefix !")
+
+	// the config check fails without the bot in the home server
+	if err = h.Discord.GuildMemberDeleteWithReason(h.Guild.ID, h.Discord.BotUser().ID, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	h.Say(h.Owner, "!config set command_prefix ?")
+
+	if reply := h.LastReply(); !strings.HasPrefix(reply, "`command_prefix` can't be set:") {
+		t.Errorf("reply = %q", reply)
+	}
+
+	rows := overrides(t, h)
+	if len(rows) != 1 || rows[0].Value != "!" {
+		t.Errorf("overrides = %+v, want the previous one", rows)
+	}
+
+	var changes int64
+	if err = h.DB.Model(&database.ConfigChange{}).Count(&changes).Error; err != nil {
+		t.Fatal(err)
+	}
+	if changes != 1 {
+		t.Errorf("%d config changes recorded, want only the first", changes)
+	}
+
+	if got := h.Bot.Config().Prefix; got != "!" {
+		t.Errorf("prefix = %q, want !", got)
+	}
+}
@@ -1,6 +1,7 @@
 package commands
 
 import (
+	"context"
 	"github.com/bwmarrin/discordgo"
 	"github.com/danvolchek/bouncer-go/lib"
 	"github.com/danvolchek/bouncer-go/lib/components"
@@ -41,33 +42,41 @@ DMing users when they are banned is {tick}{dm_bans}{tick}
 DMing users when they are warned is {tick}{dm_warns}{tick}
 `
 
-type help struct {
-	replacer *strings.Replacer
-}
+type help struct{}
 
-func (h *help) Setup(utils *lib.Utils) {
-	h.replacer = createReplacer(map[string]string{
-		"tick":     "`",
-		"prefix":   utils.Config.Prefix,
-		"dm_bans":  strconv.FormatBool(utils.Config.DM.SendBanMessage),
-		"dm_warns": strconv.FormatBool(utils.Config.DM.SendWarnMessage),
-	})
-}
+func (h *help) Setup(_ *lib.Utils) {}
 
 func (h *help) Name() string {
 	return "help"
 }
 func (h *help) RequiresUser() bool {
-	return true
+	return false
 }
 
-func (h *help) Handle(command *components.CommandDetails, message *discordgo.Message, utils *lib.Utils) bool {
-	//utils.Reply(message, h.replacer.Replace(helpMessageTemplate))
-	utils.Log.Info().Msgf("%+v", command)
+func (h *help) Description() string {
+	return "Show what commands are available"
+}
 
-	utils.Log.Info().Msg("Oh no, the sun exploded!")
+func (h *help) Arguments() []components.Argument {
+	return nil
+}
+
+func (h *help) Permission() components.Permission {
+	return components.PermissionAdmin
+}
+
+func (h *help) Handle(_ context.Context, _ *components.CommandDetails, message *discordgo.Message, utils *lib.Utils) error {
+	// built each time, since the prefix and DM settings can be changed
+	config := utils.Config()
+	replacer := createReplacer(map[string]string{
+		"tick":     "`",
+		"prefix":   config.Prefix,
+		"dm_bans":  strconv.FormatBool(config.DM.SendBanMessage),
+		"dm_warns": strconv.FormatBool(config.DM.SendWarnMessage),
+	})
 
-	return true
+	utils.Reply(message, replacer.Replace(helpMessageTemplate))
+	return nil
 }
 
 func createReplacer(variables map[string]string) *strings.Replacer {
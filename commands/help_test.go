@@ -0,0 +1,32 @@
+package commands_test
+
+import (
+	"github.com/danvolchek/bouncer-go/harness"
+	"strings"
+	"testing"
+)
+
+func TestHelp(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Say(h.Staff, "$help")
+
+	reply := h.LastReply()
+	for _, want := range []string{"Issue a warning: `$warn <user> <message>`", "DMing users when they are banned is `true`"} {
+		if !strings.Contains(reply, want) {
+			t.Errorf("reply doesn't contain %q:\n%s", want, reply)
+		}
+	}
+
+	// the help text is rebuilt when the prefix changes
+	h.Say(h.Owner, "$config set command_prefix !")
+	h.Say(h.Staff, "!help")
+
+	if reply = h.LastReply(); !strings.Contains(reply, "Issue a warning: `!warn <user> <message>`") {
+		t.Errorf("reply doesn't use the new prefix:\n%s", reply)
+	}
+}
@@ -0,0 +1,117 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/components"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// historyLimit is the number of commands to show.
+const historyLimit = 10
+
+type history struct{}
+
+func (h *history) Setup(_ *lib.Utils) {}
+
+func (h *history) Name() string {
+	return "history"
+}
+
+func (h *history) Description() string {
+	return "Show recently run commands"
+}
+
+func (h *history) Arguments() []components.Argument {
+	return []components.Argument{
+		{Name: "staff", Description: "Only show commands run by this staff member", Type: components.ArgumentUser},
+		{Name: "command", Description: "Only show this command", Type: components.ArgumentString, Autocomplete: h.commandNames},
+	}
+}
+
+func (h *history) RequiresUser() bool {
+	return false
+}
+
+func (h *history) Permission() components.Permission {
+	return components.PermissionAdmin
+}
+
+var userMentionRegexp = regexp.MustCompile(`^<@!?(\d+)>$`)
+
+func (h *history) Handle(ctx context.Context, command *components.CommandDetails, message *discordgo.Message, utils *lib.Utils) error {
+	args := command.Args
+	var staffID, name string
+	var filters []string
+
+	// the first arg is the staff member if it refers to a user, otherwise it's the command
+	if len(args) > 0 {
+		ref := args[0]
+		if match := userMentionRegexp.FindStringSubmatch(ref); match != nil {
+			ref = match[1]
+		}
+
+		user, err := utils.UserFromId(ref)
+		if err != nil {
+			user, err = utils.UserFromName(ref, message.GuildID)
+		}
+
+		if err == nil {
+			staffID = user.ID
+			filters = append(filters, "by "+user.Username)
+			args = args[1:]
+		}
+	}
+
+	if len(args) > 0 {
+		name = args[0]
+		filters = append(filters, "of `"+name+"`")
+	}
+
+	logs, err := utils.Repo().CommandHistory(ctx, message.GuildID, staffID, name, historyLimit)
+	if err != nil {
+		return err
+	}
+
+	// finding nothing is still a successful query, so it's not an error
+	if len(logs) == 0 {
+		utils.Reply(message, "No commands found.")
+		return nil
+	}
+
+	var reply strings.Builder
+	reply.WriteString("Recent commands")
+	if len(filters) > 0 {
+		reply.WriteString(" " + strings.Join(filters, " "))
+	}
+	reply.WriteString(":\n")
+
+	for _, log := range logs {
+		text := log.Text
+		if utf8.RuneCountInString(text) > 50 {
+			// cut on a character boundary so the text stays valid UTF-8
+			text = string([]rune(text)[:47]) + "..."
+		}
+
+		reply.WriteString(fmt.Sprintf("<t:%d:f> **%s**: `%s` - %s in %s (`%s`)\n",
+			log.Date.Unix(), log.Staff, strings.ReplaceAll(text, "`", "'"), log.Result, log.Duration.Round(time.Millisecond), log.UUID))
+	}
+
+	utils.Reply(message, reply.String())
+	return nil
+}
+
+// commandNames suggests names of commands that have been run.
+func (h *history) commandNames(utils *lib.Utils, partial string) []string {
+	names, err := utils.Repo().CommandNames(context.Background(), partial)
+	if err != nil {
+		utils.Log.Error().Err(err).Msg("failed to query command names")
+	}
+
+	return names
+}
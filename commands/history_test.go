@@ -0,0 +1,73 @@
+package commands_test
+
+import (
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/harness"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestHistory(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Say(h.Owner, "$config get command_prefix")
+	h.Say(h.Staff, "$history")
+
+	reply := h.LastReply()
+	if !strings.HasPrefix(reply, "Recent commands:\n") || !strings.Contains(reply, "**owner**: `$config get command_prefix` - success") {
+		t.Errorf("reply = %q", reply)
+	}
+
+	h.Say(h.Staff, "$history config")
+	if reply = h.LastReply(); !strings.HasPrefix(reply, "Recent commands of `config`:\n") || strings.Contains(reply, "history") {
+		t.Errorf("filtered reply = %q", reply)
+	}
+}
+
+func TestHistoryNothingFound(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Say(h.Staff, "$history ban")
+
+	if reply := h.LastReply(); reply != "No commands found." {
+		t.Errorf("reply = %q", reply)
+	}
+
+	var record database.CommandLog
+	if err = h.DB.Where("command = ?", "history").Take(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if record.Result != database.CommandSucceeded {
+		t.Errorf("result = %s, want %s", record.Result, database.CommandSucceeded)
+	}
+}
+
+func TestHistoryTruncatesOnCharacters(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	// two bytes per character, so cutting at a byte count would split one
+	h.Say(h.Owner, "$config get "+strings.Repeat("ü", 60))
+	h.Say(h.Staff, "$history config")
+
+	reply := h.LastReply()
+	if !utf8.ValidString(reply) {
+		t.Fatalf("reply isn't valid UTF-8: %q", reply)
+	}
+	if want := "`$config get " + strings.Repeat("ü", 35) + "...`"; !strings.Contains(reply, want) {
+		t.Errorf("reply = %q, want it to contain %q", reply, want)
+	}
+}
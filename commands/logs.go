@@ -0,0 +1,109 @@
+package commands
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/components"
+	uuid2 "github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"strings"
+	"time"
+)
+
+type logs struct{}
+
+func (l *logs) Setup(_ *lib.Utils) {}
+
+func (l *logs) Name() string {
+	return "logs"
+}
+
+func (l *logs) Description() string {
+	return "Get the logs for a command by its UUID, or how often event handlers have panicked without one"
+}
+
+func (l *logs) Arguments() []components.Argument {
+	return []components.Argument{
+		{Name: "uuid", Description: "The UUID of the command", Type: components.ArgumentString},
+	}
+}
+
+func (l *logs) RequiresUser() bool {
+	return false
+}
+
+func (l *logs) Permission() components.Permission {
+	return components.PermissionOwner
+}
+
+// Ephemeral keeps logs, which can include anything the bot saw, out of the channel when run as a slash command.
+func (l *logs) Ephemeral() bool {
+	return true
+}
+
+func (l *logs) Handle(_ context.Context, command *components.CommandDetails, message *discordgo.Message, utils *lib.Utils) error {
+	if len(command.Args) == 0 {
+		utils.Reply(message, panicCounts(utils))
+		return nil
+	}
+
+	if len(command.Args) != 1 {
+		return components.BadInput("Usage: `%slogs [uuid]`", utils.Config().Prefix)
+	}
+
+	uuid, err := uuid2.Parse(command.Args[0])
+	if err != nil {
+		return components.BadInput("`%s` isn't a valid UUID", command.Args[0])
+	}
+
+	events := utils.Logs.ByUUID(uuid.String())
+	if len(events) == 0 {
+		return components.NotFound("No logs found for `%s` - they may have been too long ago", uuid)
+	}
+
+	var out bytes.Buffer
+	writer := zerolog.ConsoleWriter{Out: &out, NoColor: true, TimeFormat: time.DateTime + " MST"}
+	for _, event := range events {
+		if _, err := writer.Write(event); err != nil {
+			return fmt.Errorf("failed to format log event: %w", err)
+		}
+	}
+
+	_, err = utils.ReplyComplex(message, &discordgo.MessageSend{
+		Content: fmt.Sprintf("%d log lines for `%s`:", len(events), uuid),
+		Files: []*discordgo.File{{
+			Name:        uuid.String() + ".log",
+			ContentType: "text/plain",
+			Reader:      &out,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send logs: %w", err)
+	}
+
+	return nil
+}
+
+// panicCounts describes how many times each component's event handlers have panicked.
+func panicCounts(utils *lib.Utils) string {
+	counts := utils.PanicCounts()
+	if len(counts) == 0 {
+		return "No event handlers have panicked."
+	}
+
+	components := maps.Keys(counts)
+	slices.Sort(components)
+
+	var out strings.Builder
+	out.WriteString("Event handler panics:")
+	for _, component := range components {
+		out.WriteString(fmt.Sprintf("\n**%s**: %d", component, counts[component]))
+	}
+
+	return out.String()
+}
@@ -0,0 +1,93 @@
+package commands_test
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/harness"
+	"strings"
+	"testing"
+)
+
+// commandUUID returns the uuid of the last run of the command.
+func commandUUID(t *testing.T, h *harness.Harness, command string) string {
+	t.Helper()
+
+	var record database.CommandLog
+	if err := h.DB.Where("command = ?", command).Order("id desc").Take(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return record.UUID
+}
+
+func TestLogs(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Say(h.Owner, "$config get command_prefix")
+	uuid := commandUUID(t, h, "config")
+
+	h.Say(h.Owner, "$logs "+uuid)
+
+	reply := lastMessage(t, h, func(message *discordgo.Message) bool { return strings.Contains(message.Content, "log lines") })
+	if len(reply.Attachments) != 1 || reply.Attachments[0].Filename != uuid+".log" {
+		t.Errorf("attachments = %v, want %s.log", reply.Attachments, uuid)
+	}
+}
+
+func TestLogsSlash(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Say(h.Owner, "$config get command_prefix")
+	uuid := commandUUID(t, h, "config")
+
+	_, err = h.Slash(h.Owner, "logs", &discordgo.ApplicationCommandInteractionDataOption{
+		Name: "uuid", Type: discordgo.ApplicationCommandOptionString, Value: uuid,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the logs replace the deferred response, so they're only visible to the owner
+	reply := lastMessage(t, h, func(message *discordgo.Message) bool { return strings.Contains(message.Content, "log lines") })
+	if reply.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Errorf("reply %q isn't ephemeral", reply.Content)
+	}
+	if len(reply.Attachments) != 1 {
+		t.Errorf("attachments = %v, want one", reply.Attachments)
+	}
+}
+
+func TestLogsPanicCounts(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Say(h.Owner, "$logs")
+	if reply := h.LastReply(); reply != "No event handlers have panicked." {
+		t.Errorf("reply = %q", reply)
+	}
+
+	h.Bot.ForComponent("test").AddHandler(func(_ *discordgo.Session, messageCreate *discordgo.MessageCreate) {
+		if messageCreate.Content == "boom" {
+			panic("boom")
+		}
+	})
+
+	h.SayIn(h.Staff, h.Channels.General.ID, "boom")
+	h.SayIn(h.Staff, h.Channels.General.ID, "boom")
+
+	h.Say(h.Owner, "$logs")
+	if reply := h.LastReply(); reply != "Event handler panics:\n**test**: 2" {
+		t.Errorf("reply = %q", reply)
+	}
+}
@@ -0,0 +1,57 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/components"
+	"regexp"
+)
+
+type say struct{}
+
+func (s *say) Setup(_ *lib.Utils) {}
+
+func (s *say) Name() string {
+	return "say"
+}
+
+func (s *say) RequiresUser() bool {
+	return false
+}
+
+func (s *say) Description() string {
+	return "Say a message as the bot"
+}
+
+func (s *say) Arguments() []components.Argument {
+	return []components.Argument{
+		{Name: "channel", Description: "The channel to send the message in", Type: components.ArgumentChannel, Required: true},
+		{Name: "message", Description: "The message to send", Type: components.ArgumentText, Required: true},
+	}
+}
+
+func (s *say) Permission() components.Permission {
+	return components.PermissionOwner
+}
+
+var channelMentionRegexp = regexp.MustCompile(`^<#(\d+)>$`)
+
+func (s *say) Handle(ctx context.Context, command *components.CommandDetails, message *discordgo.Message, utils *lib.Utils) error {
+	if len(command.Args) < 2 {
+		return components.BadInput("Usage: `%ssay <channel> <message>`", utils.Config().Prefix)
+	}
+
+	channelId := command.Args[0]
+	if match := channelMentionRegexp.FindStringSubmatch(channelId); match != nil {
+		channelId = match[1]
+	}
+
+	_, err := utils.Discord.ChannelMessageSend(channelId, command.RawFrom(1), discordgo.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("failed to send message to %s: %w", channelId, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,50 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/components"
+)
+
+type syncCommands struct {
+	// every command registered with the handler
+	registry []components.Command
+}
+
+func (s *syncCommands) SetRegistry(commands []components.Command) {
+	s.registry = commands
+}
+
+func (s *syncCommands) Setup(_ *lib.Utils) {}
+
+func (s *syncCommands) Name() string {
+	return "sync"
+}
+
+func (s *syncCommands) Description() string {
+	return "Sync slash commands to the server"
+}
+
+func (s *syncCommands) Arguments() []components.Argument {
+	return nil
+}
+
+func (s *syncCommands) RequiresUser() bool {
+	return false
+}
+
+func (s *syncCommands) Permission() components.Permission {
+	return components.PermissionOwner
+}
+
+func (s *syncCommands) Handle(ctx context.Context, _ *components.CommandDetails, message *discordgo.Message, utils *lib.Utils) error {
+	err := components.SyncApplicationCommands(ctx, utils, s.registry)
+	if err != nil {
+		return fmt.Errorf("failed to sync slash commands: %w", err)
+	}
+
+	utils.Reply(message, "Slash commands synced.")
+	return nil
+}
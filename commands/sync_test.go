@@ -0,0 +1,67 @@
+package commands_test
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/commands"
+	"github.com/danvolchek/bouncer-go/harness"
+	"testing"
+)
+
+func TestSyncRegisteredCommands(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	h.Say(h.Owner, "$sync")
+
+	if reply := h.LastReply(); reply != "Slash commands synced." {
+		t.Fatalf("reply = %q", reply)
+	}
+
+	synced := map[string]bool{}
+	for _, command := range h.Discord.Commands(h.Guild.ID) {
+		synced[command.Name] = true
+	}
+
+	if len(synced) != len(commands.All) {
+		t.Errorf("synced %d commands, want %d", len(synced), len(commands.All))
+	}
+
+	for _, command := range commands.All {
+		if !synced[command.Name()] {
+			t.Errorf("%s wasn't synced", command.Name())
+		}
+	}
+}
+
+func TestSlashEphemeral(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	_, err = h.Slash(h.Owner, "config", &discordgo.ApplicationCommandInteractionDataOption{
+		Name: "action", Type: discordgo.ApplicationCommandOptionString, Value: "get",
+	}, &discordgo.ApplicationCommandInteractionDataOption{
+		Name: "key", Type: discordgo.ApplicationCommandOptionString, Value: "command_prefix",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	messages := h.Discord.Messages(h.Channels.Commands.ID)
+	if len(messages) == 0 {
+		t.Fatal("no reply")
+	}
+
+	reply := messages[len(messages)-1]
+	if reply.Content != "`command_prefix` is `$`" {
+		t.Errorf("reply = %q", reply.Content)
+	}
+	if reply.Flags&discordgo.MessageFlagsEphemeral == 0 {
+		t.Errorf("reply %q isn't ephemeral", reply.Content)
+	}
+}
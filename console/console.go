@@ -0,0 +1,178 @@
+// Package console runs commands typed on the command line instead of sent in discord, so the database can be
+// inspected and edited during maintenance without the bot being online.
+//
+// The bot runs against a fake discord with a home server matching the config. Each line read is sent as a message
+// from a staff member in a channel commands are enabled in, and whatever the bot sends is printed.
+package console
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/fakediscord"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"io"
+	"strings"
+	"time"
+)
+
+// pollInterval is how often to check for new messages from the bot while a command runs.
+const pollInterval = 10 * time.Millisecond
+
+// stub is a fake discord for console mode. Users that aren't in it are looked up as placeholders instead of not
+// being found, so commands can refer to any user by id.
+type stub struct {
+	*fakediscord.Discord
+}
+
+func (s stub) User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error) {
+	user, err := s.Discord.User(userID, options...)
+	if err == nil {
+		return user, nil
+	}
+
+	return s.AddUserWithId(userID, userID), nil
+}
+
+// Console runs commands read from the command line.
+type Console struct {
+	// the fake discord the bot is connected to
+	discord *fakediscord.Discord
+
+	// the bot's config
+	config *lib.Config
+
+	// the staff member commands are run as
+	user *discordgo.User
+
+	// the channel commands are sent in
+	channel *discordgo.Channel
+
+	// where the bot's messages are printed
+	out io.Writer
+
+	// the running bot
+	bot *lib.Bot
+}
+
+// New starts the bot with the components against a fake discord, ready to run commands as the user with the id.
+// The user is given the admin roles from the config, so they're staff even if they're not an owner.
+// Messages the bot sends are printed to out.
+func New(components []lib.Component, config *lib.Config, db *gorm.DB, log zerolog.Logger, logs *lib.LogBuffer, userID string, out io.Writer) (*Console, error) {
+	if len(config.Categories.CommandsEnabled) == 0 {
+		return nil, errors.New("no categories have commands enabled")
+	}
+
+	fake := fakediscord.New("bouncer")
+	c := &Console{
+		discord: fake,
+		config:  config,
+		out:     out,
+	}
+
+	// configured channels exist so that what the bot posts in them is printed, and configured roles exist so the
+	// config check doesn't drop them
+	home := fake.AddConfigured(config)
+	c.channel = fake.AddChannel(home.ID, "console", discordgo.ChannelTypeGuildText, config.Categories.CommandsEnabled[0])
+
+	c.user = fake.AddUserWithId(userID, "console")
+	fake.AddMember(home.ID, c.user, config.Roles.Admin...)
+
+	c.bot = lib.NewBot(components, config, db, log, logs)
+	err := c.bot.Start(stub{Discord: fake})
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Run runs each line read from in as a command, until in is exhausted. If a command asks for confirmation, the next
+// line is the label of the button to click, e.g. Confirm. Confirmations still waiting when in is exhausted are
+// cancelled.
+func (c *Console) Run(in io.Reader) error {
+	lines := bufio.NewScanner(in)
+
+	for {
+		_, _ = fmt.Fprint(c.out, "> ")
+		if !lines.Scan() {
+			return lines.Err()
+		}
+
+		line := strings.TrimSpace(lines.Text())
+		if line != "" {
+			c.run(line, lines)
+		}
+	}
+}
+
+// Close stops the bot.
+func (c *Console) Close() {
+	c.bot.Stop()
+}
+
+// run runs the command, printing what the bot sends while it's running. Further lines are read to answer button
+// prompts.
+func (c *Console) run(command string, lines *bufio.Scanner) {
+	seen := len(c.discord.Sent())
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.discord.SendMessage(c.user, c.channel.ID, command)
+	}()
+
+	for {
+		select {
+		case <-done:
+			c.print(c.discord.Sent()[seen:])
+			return
+		case <-time.After(pollInterval):
+		}
+
+		sent := c.discord.Sent()
+		c.print(sent[seen:])
+		for _, message := range sent[seen:] {
+			if len(message.Components) != 0 {
+				c.click(message, lines)
+			}
+		}
+		seen = len(sent)
+	}
+}
+
+// click reads the label of a button on the message and clicks it. If there's nothing left to read, Cancel is clicked
+// instead.
+func (c *Console) click(message *discordgo.Message, lines *bufio.Scanner) {
+	for {
+		_, _ = fmt.Fprint(c.out, "? ")
+		if !lines.Scan() {
+			// no answer is coming, so don't wait for the prompt to time out
+			_, _ = fmt.Fprintln(c.out, "Cancel")
+			_ = c.discord.Click(c.user, c.config.Servers.Home, message, "Cancel")
+			return
+		}
+
+		err := c.discord.Click(c.user, c.config.Servers.Home, message, strings.TrimSpace(lines.Text()))
+		if err == nil {
+			return
+		}
+
+		_, _ = fmt.Fprintln(c.out, err)
+	}
+}
+
+// print prints the messages. Ones not sent in the console channel are prefixed with the channel they were sent in.
+func (c *Console) print(messages []*discordgo.Message) {
+	for _, message := range messages {
+		prefix := ""
+		if message.ChannelID != c.channel.ID {
+			prefix = fmt.Sprintf("[%s] ", c.discord.ChannelName(message.ChannelID))
+		}
+
+		_, _ = fmt.Fprintln(c.out, prefix+fakediscord.Describe(message))
+	}
+}
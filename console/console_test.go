@@ -0,0 +1,98 @@
+package console
+
+import (
+	"bytes"
+	"github.com/danvolchek/bouncer-go/commands"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/components"
+	"github.com/rs/zerolog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newConsole starts a console in a home server with a commands category, run as an owner.
+func newConsole(t *testing.T, out *bytes.Buffer) *Console {
+	t.Helper()
+
+	config := &lib.Config{
+		Prefix:  "$",
+		Servers: lib.ServerConfig{Home: "100"},
+		Users:   lib.UserConfig{Owners: []string{"200"}},
+		GuildConfig: lib.GuildConfig{
+			Categories: lib.CategoryConfig{CommandsEnabled: []string{"300"}},
+			Roles:      lib.RoleConfig{Admin: []string{"400"}},
+		},
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "bouncer.db"), config.Servers.Home)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comps, err := components.All(commands.All, components.NewSysLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := New(comps, config, db, zerolog.Nop(), lib.NewLogBuffer(10), "200", out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		c.Close()
+		_ = database.Close(db)
+	})
+
+	return c
+}
+
+// run runs the console with the input, failing if it doesn't finish in time.
+func run(t *testing.T, c *Console, input string) {
+	t.Helper()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run(strings.NewReader(input))
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("console didn't finish")
+	}
+}
+
+func TestRun(t *testing.T) {
+	var out bytes.Buffer
+	c := newConsole(t, &out)
+
+	run(t, c, "$config get command_prefix\n\n$config set command_prefix !\n!config reset command_prefix\nConfirm\n$config get command_prefix\n")
+
+	for _, want := range []string{"`command_prefix` is `$`", "`command_prefix` is now `!`", "(buttons: Confirm, Cancel)\n? `command_prefix` is now `$`"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestRunCancelsConfirmationAtEOF(t *testing.T) {
+	var out bytes.Buffer
+	c := newConsole(t, &out)
+
+	run(t, c, "$config set command_prefix !\n!config reset command_prefix\n")
+
+	// the prompt is cancelled straight away rather than after it times out
+	if !strings.Contains(out.String(), "? Cancel\n") {
+		t.Errorf("output doesn't show the prompt being cancelled:\n%s", out.String())
+	}
+
+	if prefix := c.bot.Config().Prefix; prefix != "!" {
+		t.Errorf("prefix = %q, want it to still be !", prefix)
+	}
+}
@@ -2,17 +2,20 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	gormLog "gorm.io/gorm/logger"
+	"os"
 	"time"
 )
 
-// New creates a new database. path is the path to the database.
-func New(path string) (*gorm.DB, error) {
+// New opens the database at the path, creating it if it doesn't exist, and migrates it to the latest schema version.
+// home is the home server's id - see Migrate.
+func New(path string, home string) (*gorm.DB, error) {
 	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{
 		Logger: &logger{log: log.Logger},
 	})
@@ -20,14 +23,53 @@ func New(path string) (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to create connection to db: %s", err)
 	}
 
-	err = db.AutoMigrate(allTables...)
+	err = Migrate(db, home)
 	if err != nil {
-		return nil, fmt.Errorf("failed to migrate tables: %s", err)
+		return nil, err
 	}
 
 	return db, nil
 }
 
+// NewCopy creates a new database at dst which is a copy of the one at src, replacing anything already at dst, and
+// migrates the copy. The database at src isn't changed, so it's safe to copy one another program is using.
+func NewCopy(src, dst string, home string) (*gorm.DB, error) {
+	if _, err := os.Stat(src); err != nil {
+		return nil, fmt.Errorf("failed to find db to copy: %s", err)
+	}
+
+	err := os.Remove(dst)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to remove old copy of db: %s", err)
+	}
+
+	original, err := gorm.Open(sqlite.Open(src), &gorm.Config{
+		Logger: &logger{log: log.Logger},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create connection to db: %s", err)
+	}
+
+	err = original.Exec("VACUUM INTO ?", dst).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy db: %s", err)
+	}
+
+	_ = Close(original)
+
+	return New(dst, home)
+}
+
+// Close closes the database's connection.
+func Close(db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.Close()
+}
+
 // WithLogger returns a DB session from the given session using the specified logger.
 func WithLogger(db *gorm.DB, log zerolog.Logger) *gorm.DB {
 	return db.Session(&gorm.Session{Logger: &logger{
@@ -46,16 +88,16 @@ func (l logger) LogMode(_ gormLog.LogLevel) gormLog.Interface {
 }
 
 func (l logger) Info(_ context.Context, fmt string, args ...interface{}) {
-	l.log.Info().Msgf(fmt, args)
+	l.log.Info().Msgf(fmt, args...)
 }
 
 func (l logger) Warn(_ context.Context, fmt string, args ...interface{}) {
-	l.log.Warn().Msgf(fmt, args)
+	l.log.Warn().Msgf(fmt, args...)
 
 }
 
 func (l logger) Error(_ context.Context, fmt string, args ...interface{}) {
-	l.log.Error().Msgf(fmt, args)
+	l.log.Error().Msgf(fmt, args...)
 }
 
 func (l logger) Trace(_ context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
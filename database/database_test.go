@@ -0,0 +1,62 @@
+package database
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewCopyLeavesSourceUnchanged(t *testing.T) {
+	bouncer, src := open(t)
+	statements := append(bouncerSchema,
+		"INSERT INTO badeggs (id, username, num, date, message, staff, post) VALUES (2, 'user', 1, '2023-07-01', 'spam', 'mod', 0)",
+	)
+	if err := execAll(bouncer, statements...); err != nil {
+		t.Fatal(err)
+	}
+
+	before, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dst := filepath.Join(t.TempDir(), "copy.db")
+	if err = os.WriteFile(dst, []byte("an old copy"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := NewCopy(src, dst, "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = Close(db) })
+
+	// the copy has the source's data, and is migrated
+	checkVersion(t, db, LatestSchemaVersion())
+	add(t, NewRepository(db, "home"), "home", "2", EntryWarn)
+
+	var entries []BadEgg
+	if err = db.Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Message != "spam" {
+		t.Errorf("copy's entries = %+v, want the source's and the new one", entries)
+	}
+
+	// but the source isn't touched
+	after, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(before, after) {
+		t.Error("source database changed")
+	}
+	if bouncer.Migrator().HasTable("schema_version") {
+		t.Error("source database was migrated")
+	}
+
+	if _, err = NewCopy(filepath.Join(t.TempDir(), "missing.db"), dst, "home"); err == nil {
+		t.Error("copied a database that doesn't exist")
+	}
+}
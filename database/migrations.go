@@ -0,0 +1,177 @@
+package database
+
+import (
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
+	"strings"
+	"time"
+)
+
+// migration is one step in upgrading the database schema. Steps are run in order, each in its own transaction, and the
+// version the database is at is recorded in the schema_version table.
+// Steps are written in SQL rather than from the table structs, so they keep doing the same thing as the structs
+// change. Never change a step once it's been released - add a new one instead.
+type migration struct {
+	// the schema version after this step - one more than the step before it
+	version int
+
+	// what the step does
+	description string
+
+	// performs the step. home is the home server's id, for data from before the bot ran in multiple servers.
+	up func(tx *gorm.DB, home string) error
+}
+
+// migrations are every step, in order.
+// Databases made by bouncer only have the tables from the first step, and ones made before migrations were added
+// have some of the others - so steps create and add things only if they don't exist.
+var migrations = []migration{
+	{1, "bouncer's tables", func(tx *gorm.DB, _ string) error {
+		return execAll(tx,
+			"CREATE TABLE IF NOT EXISTS `badeggs` (`dbid` integer,`id` integer,`username` text,`num` integer,`date` DATE,`message` text,`staff` text,`post` integer,PRIMARY KEY (`dbid`))",
+			"CREATE TABLE IF NOT EXISTS `blocks` (`id` text,PRIMARY KEY (`id`))",
+			"CREATE TABLE IF NOT EXISTS `staffLogs` (`staff` text,`bans` integer,`warns` integer,PRIMARY KEY (`staff`))",
+			"CREATE TABLE IF NOT EXISTS `monthLogs` (`month` text,`bans` integer,`warns` integer,PRIMARY KEY (`month`))",
+			"CREATE TABLE IF NOT EXISTS `watching` (`id` text,PRIMARY KEY (`id`))",
+			"CREATE TABLE IF NOT EXISTS `userReplyThreads` (`userid` integer,`threadid` integer,PRIMARY KEY (`userid`,`threadid`))",
+			"CREATE UNIQUE INDEX IF NOT EXISTS `idx_userReplyThreads_thread_id` ON `userReplyThreads`(`threadid`)",
+		)
+	}},
+	{2, "command log", func(tx *gorm.DB, _ string) error {
+		return execAll(tx,
+			"CREATE TABLE IF NOT EXISTS `commandLogs` (`id` integer,`uuid` text,`staff_id` text,`staff` text,`channel` text,`text` text,`command` text,`result` text,`date` datetime,`duration` integer,PRIMARY KEY (`id`))",
+			"CREATE INDEX IF NOT EXISTS `idx_commandLogs_command` ON `commandLogs`(`command`)",
+			"CREATE INDEX IF NOT EXISTS `idx_commandLogs_staff_id` ON `commandLogs`(`staff_id`)",
+			"CREATE INDEX IF NOT EXISTS `idx_commandLogs_uuid` ON `commandLogs`(`uuid`)",
+		)
+	}},
+	{3, "config overrides and changes", func(tx *gorm.DB, _ string) error {
+		return execAll(tx,
+			"CREATE TABLE IF NOT EXISTS `configOverrides` (`key` text,`value` text,PRIMARY KEY (`key`))",
+			"CREATE TABLE IF NOT EXISTS `configChanges` (`id` integer,`key` text,`action` text,`old` text,`new` text,`staff_id` text,`staff` text,`date` datetime,PRIMARY KEY (`id`))",
+			"CREATE INDEX IF NOT EXISTS `idx_configChanges_key` ON `configChanges`(`key`)",
+		)
+	}},
+	{4, "server ids, with other servers' data in tables of its own", func(tx *gorm.DB, home string) error {
+		// commands from before the bot ran in multiple servers were all run in the home server
+		if err := addColumn(tx, "commandLogs", "guild_id", "text"); err != nil {
+			return err
+		}
+
+		err := tx.Exec("UPDATE `commandLogs` SET `guild_id` = ? WHERE `guild_id` IS NULL OR `guild_id` = ''", home).Error
+		if err != nil {
+			return err
+		}
+
+		// bouncer's tables are left as they are, so bouncer can keep using them for the home server
+		return execAll(tx,
+			"CREATE INDEX IF NOT EXISTS `idx_commandLogs_guild_id` ON `commandLogs`(`guild_id`)",
+			"CREATE TABLE IF NOT EXISTS `guildBadeggs` (`dbid` integer,`guild_id` text,`id` integer,`username` text,`num` integer,`date` DATE,`message` text,`staff` text,`post` integer,PRIMARY KEY (`dbid`))",
+			"CREATE INDEX IF NOT EXISTS `idx_guildBadeggs_guild_id_id` ON `guildBadeggs`(`guild_id`,`id`)",
+			"CREATE TABLE IF NOT EXISTS `guildBlocks` (`guild_id` text,`id` text,PRIMARY KEY (`guild_id`,`id`))",
+			"CREATE TABLE IF NOT EXISTS `guildStaffLogs` (`guild_id` text,`staff` text,`bans` integer,`warns` integer,PRIMARY KEY (`guild_id`,`staff`))",
+			"CREATE TABLE IF NOT EXISTS `guildMonthLogs` (`guild_id` text,`month` text,`bans` integer,`warns` integer,PRIMARY KEY (`guild_id`,`month`))",
+			"CREATE TABLE IF NOT EXISTS `guildWatching` (`guild_id` text,`id` text,PRIMARY KEY (`guild_id`,`id`))",
+			"CREATE TABLE IF NOT EXISTS `guildUserReplyThreads` (`guild_id` text,`userid` integer,`threadid` integer,PRIMARY KEY (`userid`,`threadid`))",
+			"CREATE UNIQUE INDEX IF NOT EXISTS `idx_guildUserReplyThreads_thread_id` ON `guildUserReplyThreads`(`threadid`)",
+		)
+	}},
+	{5, "monthLogs keyed by month", func(tx *gorm.DB, _ string) error {
+		// versions before migrations made monthLogs keyed by staff, and stored watched users in it too, so step 1 left it
+		// as it was. Its stats were never written, since they were per staff rather than per month.
+		if tx.Migrator().HasColumn("monthLogs", "month") {
+			return nil
+		}
+
+		if tx.Migrator().HasColumn("monthLogs", "id") {
+			err := tx.Exec("INSERT OR IGNORE INTO `watching` (`id`) SELECT `id` FROM `monthLogs` WHERE `id` IS NOT NULL AND `id` != ''").Error
+			if err != nil {
+				return err
+			}
+		}
+
+		return execAll(tx,
+			"DROP TABLE `monthLogs`",
+			"CREATE TABLE `monthLogs` (`month` text,`bans` integer,`warns` integer,PRIMARY KEY (`month`))",
+		)
+	}},
+}
+
+// SchemaVersion is the row recorded in the schema_version table for each migration run.
+type SchemaVersion struct {
+	Version     int       `gorm:"primaryKey;column:version"`
+	Description string    `gorm:"column:description"`
+	Date        time.Time `gorm:"column:date"`
+}
+
+func (SchemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// LatestSchemaVersion is the schema version this version of the bot uses.
+func LatestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// Version returns the schema version the database is at, or 0 if no migrations have been run.
+func Version(db *gorm.DB) (int, error) {
+	var version int
+	err := db.Model(&SchemaVersion{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// Migrate runs the migrations the database hasn't had yet. home is the home server's id. It refuses to run against a
+// database with a newer schema than this version of the bot knows about, since it may not work with it.
+func Migrate(db *gorm.DB, home string) error {
+	err := db.Exec("CREATE TABLE IF NOT EXISTS `schema_version` (`version` integer,`description` text,`date` datetime,PRIMARY KEY (`version`))").Error
+	if err != nil {
+		return fmt.Errorf("failed to create schema_version table: %w", err)
+	}
+
+	current, err := Version(db)
+	if err != nil {
+		return fmt.Errorf("failed to get schema version: %w", err)
+	}
+
+	if current > LatestSchemaVersion() {
+		return fmt.Errorf("database schema is version %d, but this version of the bot only knows up to %d - update the bot", current, LatestSchemaVersion())
+	}
+
+	for _, step := range migrations[current:] {
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := step.up(tx, home); err != nil {
+				return err
+			}
+
+			return tx.Create(&SchemaVersion{Version: step.version, Description: step.description, Date: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("failed to migrate to version %d (%s): %w", step.version, step.description, err)
+		}
+
+		log.Info().Msgf("Migrated database to version %d: %s", step.version, step.description)
+	}
+
+	return nil
+}
+
+// addColumn adds the column to the table if it doesn't have it.
+func addColumn(tx *gorm.DB, table, column, columnType string) error {
+	if tx.Migrator().HasColumn(table, column) {
+		return nil
+	}
+
+	return tx.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD `%s` %s", table, column, columnType)).Error
+}
+
+// execAll runs each statement in order, stopping at the first error.
+func execAll(tx *gorm.DB, statements ...string) error {
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return fmt.Errorf("%s: %w", strings.SplitN(statement, "(", 2)[0], err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// bouncerSchema creates tables the way bouncer does.
+var bouncerSchema = []string{
+	"CREATE TABLE badeggs (dbid INTEGER PRIMARY KEY AUTOINCREMENT, id INT, username TEXT, num INT, date DATE, message TEXT, staff TEXT, post INT)",
+	"CREATE TABLE blocks (id TEXT PRIMARY KEY)",
+	"CREATE TABLE staffLogs (staff TEXT PRIMARY KEY, bans INT, warns INT)",
+	"CREATE TABLE monthLogs (month TEXT PRIMARY KEY, bans INT, warns INT)",
+	"CREATE TABLE watching (id TEXT PRIMARY KEY)",
+	"CREATE TABLE userReplyThreads (userid INT, threadid INT, PRIMARY KEY (userid, threadid))",
+}
+
+// open opens a database in a temporary directory without migrating it.
+func open(t *testing.T) (*gorm.DB, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "bouncer.db")
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = Close(db) })
+
+	return db, path
+}
+
+// migrated opens the database at the path and migrates it.
+func migrated(t *testing.T, path string) *gorm.DB {
+	t.Helper()
+
+	db, err := New(path, "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = Close(db) })
+
+	return db
+}
+
+func checkVersion(t *testing.T, db *gorm.DB, want int) {
+	t.Helper()
+
+	version, err := Version(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != want {
+		t.Errorf("schema version = %d, want %d", version, want)
+	}
+}
+
+func TestMigrateNewDatabase(t *testing.T) {
+	_, path := open(t)
+	db := migrated(t, path)
+
+	checkVersion(t, db, LatestSchemaVersion())
+
+	for _, table := range []string{
+		"badeggs", "blocks", "staffLogs", "monthLogs", "watching", "userReplyThreads",
+		"commandLogs", "configOverrides", "configChanges",
+	} {
+		if !db.Migrator().HasTable(table) {
+			t.Errorf("%s doesn't exist", table)
+		}
+	}
+
+	for _, table := range []string{"badeggs", "blocks", "staffLogs", "monthLogs", "watching", "userReplyThreads"} {
+		if !db.Migrator().HasTable(guildTable(table)) {
+			t.Errorf("%s doesn't exist", guildTable(table))
+		}
+	}
+
+	// migrating again does nothing
+	if err := Migrate(db, "home"); err != nil {
+		t.Fatal(err)
+	}
+
+	var steps int64
+	if err := db.Model(&SchemaVersion{}).Count(&steps).Error; err != nil {
+		t.Fatal(err)
+	}
+	if steps != int64(len(migrations)) {
+		t.Errorf("%d steps recorded, want %d", steps, len(migrations))
+	}
+}
+
+func TestMigrateBouncerDatabase(t *testing.T) {
+	bouncer, path := open(t)
+	statements := append(bouncerSchema,
+		"INSERT INTO badeggs (id, username, num, date, message, staff, post) VALUES (2, 'user', 1, '2023-07-01', 'spam', 'mod', 0)",
+		"INSERT INTO staffLogs (staff, bans, warns) VALUES ('3', 0, 1)",
+		"INSERT INTO blocks (id) VALUES ('2')",
+	)
+	if err := execAll(bouncer, statements...); err != nil {
+		t.Fatal(err)
+	}
+
+	db := migrated(t, path)
+	checkVersion(t, db, LatestSchemaVersion())
+
+	// bouncer's tables are as bouncer made them, so it can keep using them
+	for _, table := range []string{"badeggs", "blocks", "staffLogs", "monthLogs", "watching", "userReplyThreads"} {
+		if db.Migrator().HasColumn(table, "guild_id") {
+			t.Errorf("%s has a guild_id column", table)
+		}
+	}
+
+	var entries []BadEgg
+	if err := db.Find(&entries).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Message != "spam" {
+		t.Errorf("entries = %+v, want bouncer's", entries)
+	}
+
+	// bouncer replaces rows by their original keys
+	err := db.Exec("INSERT OR REPLACE INTO staffLogs (staff, bans, warns) VALUES ('3', 0, 2)").Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var stats []StaffLog
+	if err = db.Find(&stats).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0].Warns != 2 {
+		t.Errorf("staff stats = %+v, want bouncer's row replaced", stats)
+	}
+}
+
+func TestMigrateAssignsCommandsToHome(t *testing.T) {
+	old, path := open(t)
+
+	// a database from before the bot ran in multiple servers
+	if err := migrations[1].up(old, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := old.Exec("INSERT INTO commandLogs (uuid, command) VALUES ('a', 'warn')").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	db := migrated(t, path)
+
+	var record CommandLog
+	if err := db.Take(&record).Error; err != nil {
+		t.Fatal(err)
+	}
+	if record.GuildId != "home" {
+		t.Errorf("command's server = %q, want home", record.GuildId)
+	}
+}
+
+func TestMigrateKeysMonthLogsByMonth(t *testing.T) {
+	old, path := open(t)
+
+	// monthLogs as versions before migrations made it: keyed by staff, with watched users in it too
+	err := execAll(old,
+		"CREATE TABLE `monthLogs` (`staff` text,`bans` integer,`warns` integer,`id` text,PRIMARY KEY (`staff`))",
+		"INSERT INTO `monthLogs` (`staff`, `id`) VALUES ('', '2')",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db := migrated(t, path)
+
+	if !db.Migrator().HasColumn("monthLogs", "month") || db.Migrator().HasColumn("monthLogs", "staff") {
+		t.Error("monthLogs isn't keyed by month")
+	}
+
+	var watching []Watching
+	if err = db.Find(&watching).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(watching) != 1 || watching[0].UserId != "2" {
+		t.Errorf("watching = %+v, want the user from monthLogs", watching)
+	}
+
+	repo := NewRepository(db, "home")
+	if err = repo.IncrementStaffStats(context.Background(), "home", "3", time.Now(), EntryWarn); err != nil {
+		t.Errorf("counting stats: %v", err)
+	}
+}
+
+func TestMigrateRefusesNewerSchema(t *testing.T) {
+	_, path := open(t)
+	db := migrated(t, path)
+
+	newer := LatestSchemaVersion() + 1
+	if err := db.Create(&SchemaVersion{Version: newer}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(db, "home"); err == nil {
+		t.Error("migrated a database with a newer schema")
+	}
+	checkVersion(t, db, newer)
+}
+
+func TestMigrateRollsBackFailedStep(t *testing.T) {
+	_, path := open(t)
+	db := migrated(t, path)
+
+	released := migrations
+	t.Cleanup(func() { migrations = released })
+
+	migrations = append(migrations[:len(migrations):len(migrations)], migration{
+		version:     LatestSchemaVersion() + 1,
+		description: "broken",
+		up: func(tx *gorm.DB, _ string) error {
+			if err := tx.Exec("CREATE TABLE `broken` (`id` integer)").Error; err != nil {
+				return err
+			}
+
+			return errors.New("broken")
+		},
+	})
+
+	if err := Migrate(db, "home"); err == nil {
+		t.Fatal("failed step didn't fail the migration")
+	}
+
+	if db.Migrator().HasTable("broken") {
+		t.Error("failed step's changes weren't rolled back")
+	}
+	checkVersion(t, db, len(released))
+}
@@ -0,0 +1,490 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"strconv"
+	"time"
+)
+
+// ErrNotFound is returned when what's being looked up or changed doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// EntryKind is what a user history entry records.
+type EntryKind string
+
+const (
+	EntryNote  EntryKind = "note"
+	EntryWarn  EntryKind = "warn"
+	EntryBan   EntryKind = "ban"
+	EntryKick  EntryKind = "kick"
+	EntryUnban EntryKind = "unban"
+	EntryScam  EntryKind = "scam"
+)
+
+// entryNumbers are what's stored in BadEgg.Number for each kind of entry other than warns, which are numbered from 1
+// for each user instead.
+var entryNumbers = map[EntryKind]int{
+	EntryNote:  0,
+	EntryBan:   -1,
+	EntryKick:  -2,
+	EntryUnban: -3,
+	EntryScam:  -4,
+}
+
+// Kind returns what the entry records.
+func (b BadEgg) Kind() EntryKind {
+	if b.Number > 0 {
+		return EntryWarn
+	}
+
+	for kind, number := range entryNumbers {
+		if number == b.Number {
+			return kind
+		}
+	}
+
+	return EntryNote
+}
+
+// NewEntry is an entry to add to a user's history.
+type NewEntry struct {
+	GuildID  string
+	UserID   string
+	Username string
+	Kind     EntryKind
+	Message  string
+	Staff    string
+	Date     time.Time
+
+	// Post is the id of the message the entry was posted as in the log channel, if any.
+	Post string
+}
+
+// Repository is typed access to the bot's data, so commands don't need to query tables directly. Every method takes a
+// context, and changes to more than one table are made in a single transaction so they can't get out of sync.
+type Repository struct {
+	db *gorm.DB
+
+	// the home server's id, whose data is kept in bouncer's tables
+	home string
+}
+
+// NewRepository creates a repository for the database. home is the home server's id.
+func NewRepository(db *gorm.DB, home string) *Repository {
+	return &Repository{db: db, home: home}
+}
+
+// AddEntry adds an entry to a user's history and returns it. Warns are numbered after the user's existing warns.
+// Stats aren't counted here - the Stats component counts them from ban and warn events.
+func (r *Repository) AddEntry(ctx context.Context, entry NewEntry) (*BadEgg, error) {
+	userID, err := strconv.Atoi(entry.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("user id %q isn't a number", entry.UserID)
+	}
+
+	post := 0
+	if entry.Post != "" {
+		if post, err = strconv.Atoi(entry.Post); err != nil {
+			return nil, fmt.Errorf("post id %q isn't a number", entry.Post)
+		}
+	}
+
+	badEgg := &BadEgg{
+		GuildId:  entry.GuildID,
+		UserId:   userID,
+		Username: entry.Username,
+		Date:     entry.Date,
+		Message:  entry.Message,
+		Staff:    entry.Staff,
+		Post:     post,
+	}
+
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if entry.Kind == EntryWarn {
+			var warns int64
+			err := r.userEntries(tx, entry.GuildID, userID).Where("num > 0").Count(&warns).Error
+			if err != nil {
+				return fmt.Errorf("failed to count warns: %w", err)
+			}
+
+			badEgg.Number = int(warns) + 1
+		} else {
+			number, ok := entryNumbers[entry.Kind]
+			if !ok {
+				return fmt.Errorf("unknown entry kind %q", entry.Kind)
+			}
+
+			badEgg.Number = number
+		}
+
+		if err := r.insert(tx, entry.GuildID, "badeggs").Create(badEgg).Error; err != nil {
+			return fmt.Errorf("failed to add entry: %w", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return badEgg, nil
+}
+
+// UserHistory returns every entry in a user's history, oldest first.
+func (r *Repository) UserHistory(ctx context.Context, guildID, userID string) ([]BadEgg, error) {
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user id %q isn't a number", userID)
+	}
+
+	var entries []BadEgg
+	err = r.userEntries(r.db.WithContext(ctx), guildID, id).Order("date, dbid").Find(&entries).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user history: %w", err)
+	}
+
+	return entries, nil
+}
+
+// EditEntry changes the message of the entry at the index (from 1, in UserHistory order) in a user's history, and
+// returns the entry as it was before. ErrNotFound is returned if there's no entry at the index.
+func (r *Repository) EditEntry(ctx context.Context, guildID, userID string, index int, message string) (*BadEgg, error) {
+	var old *BadEgg
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		old, err = r.entryAt(tx, guildID, userID, index)
+		if err != nil {
+			return err
+		}
+
+		return r.table(tx, guildID, "badeggs").Where("dbid = ?", old.DbId).Update("message", message).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to edit entry: %w", err)
+	}
+
+	return old, nil
+}
+
+// RemoveEntry removes the entry at the index (from 1, in UserHistory order) in a user's history, and returns it.
+// If it's a warn, the user's later warns are renumbered. Stats aren't changed, since they count what staff did.
+// ErrNotFound is returned if there's no entry at the index.
+func (r *Repository) RemoveEntry(ctx context.Context, guildID, userID string, index int) (*BadEgg, error) {
+	var removed *BadEgg
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		removed, err = r.entryAt(tx, guildID, userID, index)
+		if err != nil {
+			return err
+		}
+
+		if err = r.table(tx, guildID, "badeggs").Where("dbid = ?", removed.DbId).Delete(&BadEgg{}).Error; err != nil {
+			return err
+		}
+
+		if removed.Kind() != EntryWarn {
+			return nil
+		}
+
+		return r.userEntries(tx, guildID, removed.UserId).
+			Where("num > ?", removed.Number).
+			Update("num", gorm.Expr("num - 1")).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove entry: %w", err)
+	}
+
+	return removed, nil
+}
+
+// IncrementStaffStats counts an action by a staff member in their and the month's stats, in a single transaction. Only
+// bans (including scams) and warns are counted - other kinds do nothing.
+func (r *Repository) IncrementStaffStats(ctx context.Context, guildID, staffID string, date time.Time, kind EntryKind) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return r.incrementStaffStats(tx, guildID, staffID, date, kind)
+	})
+}
+
+// IsBlocked returns whether a user is blocked from sending DMs to staff.
+func (r *Repository) IsBlocked(ctx context.Context, guildID, userID string) (bool, error) {
+	var count int64
+	err := r.table(r.db.WithContext(ctx), guildID, "blocks").Where("id = ?", userID).Count(&count).Error
+	if err != nil {
+		return false, fmt.Errorf("failed to check if user is blocked: %w", err)
+	}
+
+	return count > 0, nil
+}
+
+// Block blocks a user from sending DMs to staff. Blocking a blocked user does nothing.
+func (r *Repository) Block(ctx context.Context, guildID, userID string) error {
+	err := r.insert(r.db.WithContext(ctx), guildID, "blocks").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&Block{Id: userID, GuildId: guildID}).Error
+	if err != nil {
+		return fmt.Errorf("failed to block user: %w", err)
+	}
+
+	return nil
+}
+
+// Unblock unblocks a user. ErrNotFound is returned if they weren't blocked.
+func (r *Repository) Unblock(ctx context.Context, guildID, userID string) error {
+	result := r.table(r.db.WithContext(ctx), guildID, "blocks").Where("id = ?", userID).Delete(&Block{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to unblock user: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// WatchedUsers returns the ids of the users being watched.
+func (r *Repository) WatchedUsers(ctx context.Context, guildID string) ([]string, error) {
+	var ids []string
+	err := r.table(r.db.WithContext(ctx), guildID, "watching").Order("id").Pluck("id", &ids).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to get watched users: %w", err)
+	}
+
+	return ids, nil
+}
+
+// Watch starts watching a user. Watching a watched user does nothing.
+func (r *Repository) Watch(ctx context.Context, guildID, userID string) error {
+	err := r.insert(r.db.WithContext(ctx), guildID, "watching").
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&Watching{UserId: userID, GuildId: guildID}).Error
+	if err != nil {
+		return fmt.Errorf("failed to watch user: %w", err)
+	}
+
+	return nil
+}
+
+// Unwatch stops watching a user. ErrNotFound is returned if they weren't being watched.
+func (r *Repository) Unwatch(ctx context.Context, guildID, userID string) error {
+	result := r.table(r.db.WithContext(ctx), guildID, "watching").Where("id = ?", userID).Delete(&Watching{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to unwatch user: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// CommandHistory returns the server's most recently run commands, newest first. staffID and command only include
+// commands run by the staff member, or with the name, if they're set.
+func (r *Repository) CommandHistory(ctx context.Context, guildID, staffID, command string, limit int) ([]CommandLog, error) {
+	query := r.db.WithContext(ctx).Where("guild_id = ?", guildID).Order("date DESC").Limit(limit)
+	if staffID != "" {
+		query = query.Where("staff_id = ?", staffID)
+	}
+	if command != "" {
+		query = query.Where("command = ?", command)
+	}
+
+	var logs []CommandLog
+	if err := query.Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("failed to query command history: %w", err)
+	}
+
+	return logs, nil
+}
+
+// CommandNames returns the names of commands that have been run starting with prefix, in order.
+func (r *Repository) CommandNames(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := r.db.WithContext(ctx).Model(&CommandLog{}).
+		Distinct("command").
+		Where("command LIKE ?", prefix+"%").
+		Order("command").
+		Pluck("command", &names).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to query command names: %w", err)
+	}
+
+	return names, nil
+}
+
+// ConfigOverrides returns every config field set with the config command, ordered by key.
+func (r *Repository) ConfigOverrides(ctx context.Context) ([]ConfigOverride, error) {
+	var overrides []ConfigOverride
+	if err := r.db.WithContext(ctx).Order("key").Find(&overrides).Error; err != nil {
+		return nil, fmt.Errorf("failed to query config overrides: %w", err)
+	}
+
+	return overrides, nil
+}
+
+// ConfigOverride returns the override for a config field. ErrNotFound is returned if it isn't overridden.
+func (r *Repository) ConfigOverride(ctx context.Context, key string) (*ConfigOverride, error) {
+	var override ConfigOverride
+	err := r.db.WithContext(ctx).Where("key = ?", key).Take(&override).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query config overrides: %w", err)
+	}
+
+	return &override, nil
+}
+
+// LastConfigChange returns the most recent change to a config field. ErrNotFound is returned if it's never been
+// changed.
+func (r *Repository) LastConfigChange(ctx context.Context, key string) (*ConfigChange, error) {
+	var change ConfigChange
+	err := r.db.WithContext(ctx).Where("key = ?", key).Order("date DESC").Take(&change).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to query config changes: %w", err)
+	}
+
+	return &change, nil
+}
+
+// ChangeConfig overrides a config field with change.New, or removes its override for a reset, and records the change.
+func (r *Repository) ChangeConfig(ctx context.Context, change *ConfigChange) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if change.Action == ConfigReset {
+			err = tx.Delete(&ConfigOverride{Key: change.Key}).Error
+		} else {
+			err = tx.Save(&ConfigOverride{Key: change.Key, Value: change.New}).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(change).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save config change: %w", err)
+	}
+
+	return nil
+}
+
+// UndoConfigChange undoes a change made with ChangeConfig, putting back the override the field had before it, if any,
+// and removing the change's record.
+func (r *Repository) UndoConfigChange(ctx context.Context, change *ConfigChange, previous *ConfigOverride) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&ConfigOverride{Key: change.Key}).Error; err != nil {
+			return err
+		}
+
+		if previous != nil {
+			if err := tx.Create(previous).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(change).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to undo config change: %w", err)
+	}
+
+	return nil
+}
+
+// table returns a query for a server's rows in one of bouncer's tables.
+func (r *Repository) table(tx *gorm.DB, guildID, table string) *gorm.DB {
+	if guildID == r.home {
+		return tx.Table(table)
+	}
+
+	return tx.Table(guildTable(table)).Where("guild_id = ?", guildID)
+}
+
+// insert returns a query for adding a server's rows to one of bouncer's tables. The home server's rows are added
+// without their server, since bouncer's tables don't have a column for it.
+func (r *Repository) insert(tx *gorm.DB, guildID, table string) *gorm.DB {
+	if guildID == r.home {
+		return tx.Table(table).Omit("guild_id")
+	}
+
+	return tx.Table(guildTable(table))
+}
+
+// userEntries returns a query for a user's history entries.
+func (r *Repository) userEntries(tx *gorm.DB, guildID string, userID int) *gorm.DB {
+	return r.table(tx, guildID, "badeggs").Where("id = ?", userID)
+}
+
+// entryAt returns the entry at the index (from 1, in UserHistory order) in a user's history.
+func (r *Repository) entryAt(tx *gorm.DB, guildID, userID string, index int) (*BadEgg, error) {
+	id, err := strconv.Atoi(userID)
+	if err != nil {
+		return nil, fmt.Errorf("user id %q isn't a number", userID)
+	}
+
+	if index < 1 {
+		return nil, ErrNotFound
+	}
+
+	var entry BadEgg
+	err = r.userEntries(tx, guildID, id).Order("date, dbid").Offset(index - 1).Take(&entry).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// incrementStaffStats adds one to the staff member's and month's count of the kind of action, if it's counted.
+func (r *Repository) incrementStaffStats(tx *gorm.DB, guildID, staffID string, date time.Time, kind EntryKind) error {
+	var column string
+	switch kind {
+	case EntryBan, EntryScam:
+		column = "bans"
+	case EntryWarn:
+		column = "warns"
+	default:
+		return nil
+	}
+
+	err := r.upsertStat(tx, guildID, "staffLogs", "staff", staffID, column)
+	if err != nil {
+		return fmt.Errorf("failed to update staff stats: %w", err)
+	}
+
+	err = r.upsertStat(tx, guildID, "monthLogs", "month", date.Format(MonthFormat), column)
+	if err != nil {
+		return fmt.Errorf("failed to update month stats: %w", err)
+	}
+
+	return nil
+}
+
+// upsertStat adds one to the column of the stats table's row for key, adding the row if there isn't one.
+func (r *Repository) upsertStat(tx *gorm.DB, guildID, table, keyColumn, key, column string) error {
+	row := map[string]any{keyColumn: key, "bans": 0, "warns": 0}
+	row[column] = 1
+
+	conflict := []clause.Column{{Name: keyColumn}}
+	if guildID != r.home {
+		row["guild_id"] = guildID
+		conflict = append(conflict, clause.Column{Name: "guild_id"})
+	}
+
+	increment := map[string]any{column: gorm.Expr(column + " + 1")}
+
+	return r.insert(tx, guildID, table).
+		Clauses(clause.OnConflict{Columns: conflict, DoUpdates: clause.Assignments(increment)}).
+		Create(row).Error
+}
@@ -0,0 +1,298 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/exp/slices"
+	"testing"
+	"time"
+)
+
+func newRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	_, path := open(t)
+	return NewRepository(migrated(t, path), "home")
+}
+
+// add adds entries of the kinds to a user's history in order, a day apart.
+func add(t *testing.T, repo *Repository, guildID, userID string, kinds ...EntryKind) []*BadEgg {
+	t.Helper()
+
+	date := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	var added []*BadEgg
+	for i, kind := range kinds {
+		entry, err := repo.AddEntry(context.Background(), NewEntry{
+			GuildID: guildID,
+			UserID:  userID,
+			Kind:    kind,
+			Message: string(kind),
+			Date:    date.AddDate(0, 0, i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		added = append(added, entry)
+	}
+
+	return added
+}
+
+// numbers returns the numbers of the entries in a user's history.
+func numbers(t *testing.T, repo *Repository, guildID, userID string) []int {
+	t.Helper()
+
+	entries, err := repo.UserHistory(context.Background(), guildID, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var numbers []int
+	for _, entry := range entries {
+		numbers = append(numbers, entry.Number)
+	}
+
+	return numbers
+}
+
+func TestAddEntryNumbersWarns(t *testing.T) {
+	repo := newRepository(t)
+
+	add(t, repo, "home", "2", EntryWarn, EntryNote, EntryBan, EntryWarn)
+	add(t, repo, "home", "5", EntryWarn)
+	add(t, repo, "other", "2", EntryWarn)
+
+	// warns are numbered per user and server, other kinds have fixed numbers
+	if got := numbers(t, repo, "home", "2"); !slices.Equal(got, []int{1, 0, -1, 2}) {
+		t.Errorf("numbers = %v, want [1 0 -1 2]", got)
+	}
+	if got := numbers(t, repo, "home", "5"); !slices.Equal(got, []int{1}) {
+		t.Errorf("other user's numbers = %v, want [1]", got)
+	}
+	if got := numbers(t, repo, "other", "2"); !slices.Equal(got, []int{1}) {
+		t.Errorf("other server's numbers = %v, want [1]", got)
+	}
+
+	if _, err := repo.AddEntry(context.Background(), NewEntry{GuildID: "home", UserID: "2", Kind: "hug"}); err == nil {
+		t.Error("added an entry of an unknown kind")
+	}
+}
+
+func TestRemoveEntryRenumbersWarns(t *testing.T) {
+	repo := newRepository(t)
+	ctx := context.Background()
+
+	add(t, repo, "home", "2", EntryWarn, EntryWarn, EntryNote, EntryWarn)
+	add(t, repo, "home", "5", EntryWarn, EntryWarn)
+
+	removed, err := repo.RemoveEntry(ctx, "home", "2", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed.Number != 1 {
+		t.Errorf("removed %+v, want the first warn", removed)
+	}
+
+	// the later warns move down, and other users' don't change
+	if got := numbers(t, repo, "home", "2"); !slices.Equal(got, []int{1, 0, 2}) {
+		t.Errorf("numbers = %v, want [1 0 2]", got)
+	}
+	if got := numbers(t, repo, "home", "5"); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("other user's numbers = %v, want [1 2]", got)
+	}
+
+	// removing something other than a warn doesn't renumber anything
+	if _, err = repo.RemoveEntry(ctx, "home", "2", 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := numbers(t, repo, "home", "2"); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("numbers = %v, want [1 2]", got)
+	}
+
+	for _, index := range []int{0, 3} {
+		if _, err = repo.RemoveEntry(ctx, "home", "2", index); !errors.Is(err, ErrNotFound) {
+			t.Errorf("removing entry %d: err = %v, want not found", index, err)
+		}
+	}
+}
+
+func TestIncrementStaffStats(t *testing.T) {
+	repo := newRepository(t)
+	ctx := context.Background()
+
+	july := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	august := time.Date(2023, 8, 1, 0, 0, 0, 0, time.UTC)
+	for _, action := range []struct {
+		guildID string
+		date    time.Time
+		kind    EntryKind
+	}{
+		{"home", july, EntryBan},
+		{"home", july, EntryWarn},
+		{"home", july, EntryScam},
+		{"home", july, EntryNote},
+		{"home", july, EntryKick},
+		{"home", august, EntryBan},
+		{"other", july, EntryWarn},
+	} {
+		if err := repo.IncrementStaffStats(ctx, action.guildID, "3", action.date, action.kind); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var staff []StaffLog
+	if err := repo.db.Find(&staff).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(staff) != 1 || staff[0].Staff != "3" || staff[0].Bans != 3 || staff[0].Warns != 1 {
+		t.Errorf("staff stats = %+v, want 3 bans and 1 warn", staff)
+	}
+
+	var months []MonthLog
+	if err := repo.db.Order("month").Find(&months).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(months) != 2 || months[0].Bans != 2 || months[0].Warns != 1 || months[1].Month != "2023-08" || months[1].Bans != 1 {
+		t.Errorf("month stats = %+v, want July with 2 bans and 1 warn, and August with 1 ban", months)
+	}
+
+	// other servers are counted separately
+	if err := repo.db.Table(guildTable("staffLogs")).Find(&staff).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(staff) != 1 || staff[0].GuildId != "other" || staff[0].Bans != 0 || staff[0].Warns != 1 {
+		t.Errorf("other server's staff stats = %+v, want 1 warn", staff)
+	}
+
+	// adding entries doesn't count them again
+	add(t, repo, "home", "2", EntryBan, EntryWarn)
+	if err := repo.db.Find(&staff).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(staff) != 1 || staff[0].Bans != 3 || staff[0].Warns != 1 {
+		t.Errorf("staff stats after adding entries = %+v, want them unchanged", staff)
+	}
+}
+
+func TestHomeUsesBouncerTables(t *testing.T) {
+	repo := newRepository(t)
+	ctx := context.Background()
+
+	// rows bouncer wrote
+	err := execAll(repo.db,
+		"INSERT INTO badeggs (id, username, num, date, message, staff, post) VALUES (2, 'user', 1, '2023-07-01', 'spam', 'mod', 0)",
+		"INSERT INTO blocks (id) VALUES ('2')",
+		"INSERT INTO watching (id) VALUES ('2')",
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// are the home server's
+	add(t, repo, "home", "2", EntryWarn)
+	if got := numbers(t, repo, "home", "2"); !slices.Equal(got, []int{1, 2}) {
+		t.Errorf("numbers = %v, want bouncer's warn then the new one", got)
+	}
+
+	if blocked, err := repo.IsBlocked(ctx, "home", "2"); err != nil || !blocked {
+		t.Errorf("blocked = %t, %v, want blocked", blocked, err)
+	}
+
+	if watched, err := repo.WatchedUsers(ctx, "home"); err != nil || len(watched) != 1 {
+		t.Errorf("watched = %v, %v, want bouncer's", watched, err)
+	}
+
+	// but not other servers'
+	if got := numbers(t, repo, "other", "2"); len(got) != 0 {
+		t.Errorf("other server's numbers = %v, want none", got)
+	}
+
+	if blocked, err := repo.IsBlocked(ctx, "other", "2"); err != nil || blocked {
+		t.Errorf("other server's blocked = %t, %v, want not blocked", blocked, err)
+	}
+
+	if err = repo.Block(ctx, "other", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err = repo.Unblock(ctx, "home", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if blocked, err := repo.IsBlocked(ctx, "other", "2"); err != nil || !blocked {
+		t.Errorf("other server's blocked = %t, %v, want blocked", blocked, err)
+	}
+	if err = repo.Unblock(ctx, "home", "2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unblocking twice: err = %v, want not found", err)
+	}
+}
+
+func TestGuildsAreKeptApart(t *testing.T) {
+	repo := newRepository(t)
+	ctx := context.Background()
+
+	add(t, repo, "home", "2", EntryWarn, EntryNote)
+	add(t, repo, "other", "2", EntryWarn, EntryWarn)
+
+	// changing one server's history doesn't change another's
+	if _, err := repo.EditEntry(ctx, "other", "2", 1, "edited"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.RemoveEntry(ctx, "home", "2", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	home, err := repo.UserHistory(ctx, "home", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(home) != 1 || home[0].Message != string(EntryNote) {
+		t.Errorf("home history = %+v, want only the note", home)
+	}
+
+	other, err := repo.UserHistory(ctx, "other", "2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(other) != 2 || other[0].Message != "edited" || other[0].Number != 1 || other[1].Number != 2 {
+		t.Errorf("other server's history = %+v, want both warns, the first edited", other)
+	}
+
+	if got := numbers(t, repo, "third", "2"); len(got) != 0 {
+		t.Errorf("third server's numbers = %v, want none", got)
+	}
+
+	// the home server's rows are in bouncer's tables, and the others' are in their own with their server's id
+	var count int64
+	if err = repo.db.Table("badeggs").Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("badeggs has %d rows, want the home server's 1", count)
+	}
+
+	var guildIDs []string
+	if err = repo.db.Table(guildTable("badeggs")).Pluck("guild_id", &guildIDs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(guildIDs, []string{"other", "other"}) {
+		t.Errorf("%s server ids = %v, want the other server's 2", guildTable("badeggs"), guildIDs)
+	}
+
+	// watching too
+	if err = repo.Watch(ctx, "other", "2"); err != nil {
+		t.Fatal(err)
+	}
+	for guildID, want := range map[string]int{"home": 0, "other": 1, "third": 0} {
+		watched, err := repo.WatchedUsers(ctx, guildID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(watched) != want {
+			t.Errorf("%s watched = %v, want %d", guildID, watched, want)
+		}
+	}
+	if err = repo.Unwatch(ctx, "home", "2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unwatching in the home server: err = %v, want not found", err)
+	}
+}
@@ -1,15 +1,24 @@
 package database
 
 import (
+	"strings"
 	"time"
 )
 
 // Note: All the explicit column/table names are explicitly set to match the current DB structure
+// The tables are created and changed by migrations (see migrations.go) - changing a struct here needs one too.
+// Bouncer's tables hold the home server's data, and are kept exactly as bouncer made them so it can keep sharing the
+// database. Other servers' data is kept in copies of them with a guild_id column (see guildTable) - GuildId is only
+// stored in those.
 
-var allTables = []any{&BadEgg{}, &Block{}, &StaffLog{}, &MonthLog{}, &Watching{}, &UserReplyThread{}}
+// guildTable returns the name of the table other servers' rows for one of bouncer's tables are kept in.
+func guildTable(table string) string {
+	return "guild" + strings.ToUpper(table[:1]) + table[1:]
+}
 
 type BadEgg struct {
 	DbId     int       `gorm:"primaryKey;column:dbid"`
+	GuildId  string    `gorm:"column:guild_id"`
 	UserId   int       `gorm:"column:id"`
 	Username string    `gorm:"column:username"`
 	Number   int       `gorm:"column:num"`
@@ -24,46 +33,131 @@ func (BadEgg) TableName() string {
 }
 
 type Block struct {
-	Id string `gorm:"primaryKey;column:id"`
+	Id      string `gorm:"primaryKey;column:id"`
+	GuildId string `gorm:"column:guild_id"`
 }
 
 func (Block) TableName() string {
 	return "blocks"
 }
 
+// StaffLog counts bans and warns per staff member. Staff is the staff member's user id.
 type StaffLog struct {
-	Staff string `gorm:"primaryKey;column:staff"`
-	Bans  int    `gorm:"column:bans"`
-	Warns int    `gorm:"column:warns"`
+	Staff   string `gorm:"primaryKey;column:staff"`
+	GuildId string `gorm:"column:guild_id"`
+	Bans    int    `gorm:"column:bans"`
+	Warns   int    `gorm:"column:warns"`
 }
 
 func (StaffLog) TableName() string {
 	return "staffLogs"
 }
 
+// MonthLog counts bans and warns per month. Month is formatted as MonthFormat.
 type MonthLog struct {
-	Staff string `gorm:"primaryKey;column:staff"`
-	Bans  int    `gorm:"column:bans"`
-	Warns int    `gorm:"column:warns"`
+	Month   string `gorm:"primaryKey;column:month"`
+	GuildId string `gorm:"column:guild_id"`
+	Bans    int    `gorm:"column:bans"`
+	Warns   int    `gorm:"column:warns"`
 }
 
 func (MonthLog) TableName() string {
 	return "monthLogs"
 }
 
+// MonthFormat is the time format of MonthLog.Month.
+const MonthFormat = "2006-01"
+
 type Watching struct {
-	UserId string `gorm:"primaryKey;column:id"`
+	UserId  string `gorm:"primaryKey;column:id"`
+	GuildId string `gorm:"column:guild_id"`
 }
 
 func (Watching) TableName() string {
-	return "monthLogs"
+	return "watching"
 }
 
 type UserReplyThread struct {
-	UserId   int `gorm:"primaryKey;column:userid"`
-	ThreadId int `gorm:"primaryKey;uniqueIndex;column:threadid"`
+	UserId   int    `gorm:"primaryKey;column:userid"`
+	ThreadId int    `gorm:"primaryKey;uniqueIndex;column:threadid"`
+	GuildId  string `gorm:"column:guild_id"`
 }
 
 func (UserReplyThread) TableName() string {
 	return "userReplyThreads"
 }
+
+// CommandLog is a record of a command being run. Unlike the other tables, this one doesn't exist in bouncer.
+type CommandLog struct {
+	Id       int           `gorm:"primaryKey;column:id"`
+	UUID     string        `gorm:"column:uuid;index"`
+	GuildId  string        `gorm:"column:guild_id;index"`
+	StaffId  string        `gorm:"column:staff_id;index"`
+	Staff    string        `gorm:"column:staff"`
+	Channel  string        `gorm:"column:channel"`
+	Text     string        `gorm:"column:text"`
+	Command  string        `gorm:"column:command;index"`
+	Result   CommandResult `gorm:"column:result"`
+	Date     time.Time     `gorm:"column:date"`
+	Duration time.Duration `gorm:"column:duration"`
+}
+
+func (CommandLog) TableName() string {
+	return "commandLogs"
+}
+
+// CommandResult is the outcome of running a command.
+type CommandResult string
+
+const (
+	// CommandSucceeded means the command ran successfully.
+	CommandSucceeded CommandResult = "success"
+
+	// CommandFailed means the command ran but failed.
+	CommandFailed CommandResult = "failed"
+
+	// CommandPanicked means the command panicked while running.
+	CommandPanicked CommandResult = "panicked"
+
+	// CommandRejected means the command wasn't run, e.g. because it doesn't exist or the user didn't have permission.
+	CommandRejected CommandResult = "rejected"
+)
+
+// ConfigOverride is a config field set with the config command, which takes precedence over the config file. Value is
+// formatted the way lib.Config.Set takes it. Unlike the other tables, this one doesn't exist in bouncer.
+type ConfigOverride struct {
+	Key   string `gorm:"primaryKey;column:key"`
+	Value string `gorm:"column:value"`
+}
+
+func (ConfigOverride) TableName() string {
+	return "configOverrides"
+}
+
+// ConfigChange is a record of a config field being changed with the config command. Old and New are the field's
+// values before and after - New is empty for resets. Unlike the other tables, this one doesn't exist in bouncer.
+type ConfigChange struct {
+	Id      int              `gorm:"primaryKey;column:id"`
+	Key     string           `gorm:"column:key;index"`
+	Action  ConfigChangeKind `gorm:"column:action"`
+	Old     string           `gorm:"column:old"`
+	New     string           `gorm:"column:new"`
+	StaffId string           `gorm:"column:staff_id"`
+	Staff   string           `gorm:"column:staff"`
+	Date    time.Time        `gorm:"column:date"`
+}
+
+func (ConfigChange) TableName() string {
+	return "configChanges"
+}
+
+// ConfigChangeKind is how a config field was changed.
+type ConfigChangeKind string
+
+const (
+	// ConfigSet means the field was overridden with a new value.
+	ConfigSet ConfigChangeKind = "set"
+
+	// ConfigReset means the field's override was removed, so it's back to the config file's value.
+	ConfigReset ConfigChangeKind = "reset"
+)
@@ -0,0 +1,245 @@
+// Package harness runs the whole bot - every component and command - against a fake discord and a scratch
+// database, so tests can script conversations with it and check what it did.
+//
+// A typical test creates a harness, has users send messages, and checks the bot's posts, DMs and database rows:
+//
+//	h, err := harness.New(t.TempDir())
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer h.Close()
+//
+//	h.Say(h.Owner, "$say "+h.Channels.General.Mention()+" hello")
+//	// check h.Posts(h.Channels.General.ID), h.Replies(), h.DMs(user), h.DB, ...
+package harness
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/commands"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/components"
+	"github.com/danvolchek/bouncer-go/lib/fakediscord"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+	"path/filepath"
+	"time"
+)
+
+// waitTimeout is how long WaitFor waits.
+const waitTimeout = 5 * time.Second
+
+// Harness is a running bot connected to a fake discord with a home server set up to match its config.
+type Harness struct {
+	// Discord is the fake discord the bot is connected to.
+	Discord *fakediscord.Discord
+
+	// DB is the bot's database.
+	DB *gorm.DB
+
+	// Config is the config the bot was created with. The bot uses a checked copy - see Bot.Config, and use
+	// Bot.ReloadConfig to change it.
+	Config *lib.Config
+
+	// Logs holds the bot's log events.
+	Logs *lib.LogBuffer
+
+	// Bot is the running bot.
+	Bot *lib.Bot
+
+	// Guild is the home server.
+	Guild *discordgo.Guild
+
+	// Staff is a member with the admin role, and Owner is a bot owner.
+	Staff, Owner *discordgo.User
+
+	// Roles in the home server.
+	Roles struct {
+		Admin, Owner *discordgo.Role
+	}
+
+	// Channels in the home server. Commands is in the category commands are enabled in, General isn't.
+	Channels struct {
+		Commands, General, Mailbox, Spam, Log, SysLog, Watchlist, BanAppeal *discordgo.Channel
+	}
+}
+
+// New creates a home server in a fake discord, and starts the bot with every component and command in it.
+// dir is where the database is created. Log events are kept in Logs rather than printed.
+func New(dir string) (*Harness, error) {
+	fake := fakediscord.New("bouncer")
+
+	h := &Harness{Discord: fake}
+
+	h.Guild = fake.AddGuild("home")
+	h.Roles.Admin = fake.AddRole(h.Guild.ID, "admin", discordgo.PermissionAdministrator)
+	h.Roles.Owner = fake.AddRole(h.Guild.ID, "owner", discordgo.PermissionAdministrator)
+
+	staffCategory := fake.AddChannel(h.Guild.ID, "staff", discordgo.ChannelTypeGuildCategory, "")
+	text := func(name, parent string) *discordgo.Channel {
+		return fake.AddChannel(h.Guild.ID, name, discordgo.ChannelTypeGuildText, parent)
+	}
+	h.Channels.Commands = text("bot-commands", staffCategory.ID)
+	h.Channels.General = text("general", "")
+	h.Channels.Mailbox = text("mailbox", staffCategory.ID)
+	h.Channels.Spam = text("spam", staffCategory.ID)
+	h.Channels.Log = text("log", staffCategory.ID)
+	h.Channels.SysLog = text("syslog", staffCategory.ID)
+	h.Channels.Watchlist = text("watchlist", staffCategory.ID)
+	h.Channels.BanAppeal = text("ban-appeal", staffCategory.ID)
+
+	h.Staff = h.AddUser("staff", h.Roles.Admin.ID)
+	h.Owner = h.AddUser("owner", h.Roles.Owner.ID)
+
+	h.Config = &lib.Config{
+		Token:   "fake",
+		Prefix:  "$",
+		Servers: lib.ServerConfig{Home: h.Guild.ID},
+		GuildConfig: lib.GuildConfig{
+			Categories: lib.CategoryConfig{CommandsEnabled: []string{staffCategory.ID}},
+			Channels: lib.ChannelConfig{
+				Mailbox:   h.Channels.Mailbox.ID,
+				Spam:      h.Channels.Spam.ID,
+				Log:       h.Channels.Log.ID,
+				SysLog:    h.Channels.SysLog.ID,
+				Watchlist: h.Channels.Watchlist.ID,
+				BanAppeal: h.Channels.BanAppeal.ID,
+			},
+			Roles: lib.RoleConfig{
+				Admin: []string{h.Roles.Admin.ID},
+				Owner: []string{h.Roles.Owner.ID},
+			},
+			DM: lib.DMConfig{SendBanMessage: true, SendWarnMessage: true},
+		},
+	}
+
+	h.Logs = lib.NewLogBuffer(10000)
+	sysLog := components.NewSysLog()
+	log := zerolog.New(zerolog.MultiLevelWriter(h.Logs, sysLog)).Level(zerolog.DebugLevel).With().Timestamp().Logger()
+
+	db, err := database.New(filepath.Join(dir, "bouncer.db"), h.Guild.ID)
+	if err != nil {
+		return nil, err
+	}
+	db = database.WithLogger(db, log)
+	h.DB = db
+
+	comps, err := components.All(commands.All, sysLog)
+	if err != nil {
+		_ = database.Close(db)
+		return nil, err
+	}
+
+	h.Bot = lib.NewBot(comps, h.Config, db, log, h.Logs)
+	err = h.Bot.Start(fake)
+	if err != nil {
+		_ = database.Close(db)
+		return nil, err
+	}
+
+	return h, nil
+}
+
+// Close stops the bot and closes the database.
+func (h *Harness) Close() {
+	h.Bot.Stop()
+	_ = database.Close(h.DB)
+}
+
+// AddUser adds a member to the home server with the roles.
+func (h *Harness) AddUser(name string, roleIDs ...string) *discordgo.User {
+	user := h.Discord.AddUser(name)
+	h.Discord.AddMember(h.Guild.ID, user, roleIDs...)
+	return user
+}
+
+// Say sends a message from the author in the commands channel, and waits for the bot to handle it.
+func (h *Harness) Say(author *discordgo.User, content string) *discordgo.Message {
+	return h.SayIn(author, h.Channels.Commands.ID, content)
+}
+
+// SayIn sends a message from the author in the channel, and waits for the bot to handle it.
+func (h *Harness) SayIn(author *discordgo.User, channelID, content string) *discordgo.Message {
+	return h.Discord.SendMessage(author, channelID, content)
+}
+
+// SayAsync sends a message from the author in the commands channel without waiting for the bot to handle it, e.g.
+// for commands that wait for confirmation. The returned function waits for the bot to finish handling it.
+func (h *Harness) SayAsync(author *discordgo.User, content string) (wait func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.Say(author, content)
+	}()
+
+	return func() { <-done }
+}
+
+// DM sends a direct message from the user to the bot, and waits for the bot to handle it.
+func (h *Harness) DM(author *discordgo.User, content string) *discordgo.Message {
+	return h.Discord.SendDM(author, content)
+}
+
+// Slash runs the slash command with the options as the author in the commands channel, and waits for the bot to
+// handle it. Replies show up in Replies like other messages, with their flags set.
+func (h *Harness) Slash(author *discordgo.User, name string, options ...*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.Interaction, error) {
+	return h.Discord.SlashCommand(author, h.Channels.Commands.ID, name, options...)
+}
+
+// Click clicks the button with the label on the message as the user, and waits for the bot to handle it.
+func (h *Harness) Click(user *discordgo.User, message *discordgo.Message, label string) error {
+	return h.Discord.Click(user, h.Guild.ID, message, label)
+}
+
+// WaitFor waits until the condition is true, returning false if it isn't within a few seconds.
+func (h *Harness) WaitFor(condition func() bool) bool {
+	deadline := time.Now().Add(waitTimeout)
+	for time.Now().Before(deadline) {
+		if condition() {
+			return true
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return false
+}
+
+// Posts returns the content of the messages the bot has posted in the channel, oldest first.
+func (h *Harness) Posts(channelID string) []string {
+	var posts []string
+	for _, message := range h.Discord.Messages(channelID) {
+		if message.Author.ID == h.Discord.BotUser().ID {
+			posts = append(posts, message.Content)
+		}
+	}
+
+	return posts
+}
+
+// Replies returns the content of the messages the bot has posted in the commands channel, oldest first.
+func (h *Harness) Replies() []string {
+	return h.Posts(h.Channels.Commands.ID)
+}
+
+// LastReply returns the content of the last message the bot posted in the commands channel, or an empty string.
+func (h *Harness) LastReply() string {
+	replies := h.Replies()
+	if len(replies) == 0 {
+		return ""
+	}
+
+	return replies[len(replies)-1]
+}
+
+// DMs returns the content of the direct messages the bot has sent the user, oldest first.
+func (h *Harness) DMs(user *discordgo.User) []string {
+	var dms []string
+	for _, message := range h.Discord.DMs(user.ID) {
+		if message.Author.ID == h.Discord.BotUser().ID {
+			dms = append(dms, message.Content)
+		}
+	}
+
+	return dms
+}
@@ -0,0 +1,181 @@
+package harness_test
+
+import (
+	"context"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/harness"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/events"
+	"golang.org/x/exp/slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newHarness(t *testing.T) *harness.Harness {
+	t.Helper()
+
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(h.Close)
+
+	return h
+}
+
+// commandLogs returns the commands recorded in the audit log, oldest first.
+func commandLogs(t *testing.T, h *harness.Harness) []database.CommandLog {
+	t.Helper()
+
+	var records []database.CommandLog
+	if err := h.DB.Order("id").Find(&records).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return records
+}
+
+func TestSay(t *testing.T) {
+	h := newHarness(t)
+
+	h.Say(h.Owner, "$say "+h.Channels.General.Mention()+" hello there")
+
+	if posts := h.Posts(h.Channels.General.ID); len(posts) != 1 || posts[0] != "hello there" {
+		t.Errorf("general posts = %q, want [hello there]", posts)
+	}
+
+	records := commandLogs(t, h)
+	if len(records) != 1 {
+		t.Fatalf("%d commands logged, want 1", len(records))
+	}
+	if record := records[0]; record.Command != "say" || record.StaffId != h.Owner.ID || record.Result != database.CommandSucceeded {
+		t.Errorf("record = %+v, want a successful say by the owner", record)
+	}
+}
+
+func TestOneReplyAtInfoLevel(t *testing.T) {
+	h := newHarness(t)
+
+	// the uuid is only sent first when logging at debug level
+	h.Say(h.Staff, "$help")
+
+	if replies := h.Replies(); len(replies) != 1 || !strings.Contains(replies[0], "Issue a warning") {
+		t.Errorf("replies = %q, want only the help text", replies)
+	}
+}
+
+func TestIgnoredMessages(t *testing.T) {
+	h := newHarness(t)
+	member := h.AddUser("member")
+
+	h.Say(member, "$say "+h.Channels.General.Mention()+" hi")
+	h.SayIn(h.Staff, h.Channels.General.ID, "$say "+h.Channels.General.Mention()+" hi")
+	h.Say(h.Staff, "say "+h.Channels.General.Mention()+" hi")
+
+	if replies := h.Replies(); len(replies) != 0 {
+		t.Errorf("replies = %q, want none", replies)
+	}
+	if posts := h.Posts(h.Channels.General.ID); len(posts) != 0 {
+		t.Errorf("general posts = %q, want none", posts)
+	}
+	if records := commandLogs(t, h); len(records) != 0 {
+		t.Errorf("%d commands logged, want none", len(records))
+	}
+}
+
+func TestPermissionDenied(t *testing.T) {
+	h := newHarness(t)
+
+	h.Say(h.Staff, "$sync")
+
+	if reply := h.LastReply(); reply != "Sorry, you need the `owner` permission to use `$sync`." {
+		t.Errorf("reply = %q", reply)
+	}
+
+	records := commandLogs(t, h)
+	if len(records) != 1 || records[0].Result != database.CommandRejected {
+		t.Errorf("records = %+v, want one rejected command", records)
+	}
+}
+
+func TestSharedBans(t *testing.T) {
+	h := newHarness(t)
+
+	other := h.Discord.AddGuild("other")
+	user := h.AddUser("spammer")
+	h.Discord.AddMember(other.ID, user)
+
+	config := h.Bot.Config().Copy()
+	config.SharedBans = true
+	config.Guilds = map[string]*lib.GuildConfig{other.ID: {SharedBans: true}}
+	if err := h.Bot.ReloadConfig(config); err != nil {
+		t.Fatal(err)
+	}
+
+	// someone bans the user from the home server without the bot
+	if err := h.Discord.GuildBanCreateWithReason(h.Guild.ID, user.ID, "spam", 0); err != nil {
+		t.Fatal(err)
+	}
+	h.Discord.Dispatch(&discordgo.GuildBanAdd{GuildID: h.Guild.ID, User: user})
+
+	ban, err := h.Discord.GuildBan(other.ID, user.ID)
+	if err != nil {
+		t.Fatalf("ban wasn't shared: %s", err)
+	}
+	if ban.Reason != "Shared ban from home: spam" {
+		t.Errorf("reason = %q", ban.Reason)
+	}
+}
+
+func TestModerationEvents(t *testing.T) {
+	h := newHarness(t)
+	user := h.AddUser("spammer")
+	date := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+
+	ctx := context.Background()
+	lib.Publish(ctx, h.Bot.Utils, events.UserWarned{Guild: h.Guild.ID, User: user, Staff: h.Staff, Reason: "spam", Count: 1, Date: date})
+	lib.Publish(ctx, h.Bot.Utils, events.UserBanned{Guild: h.Guild.ID, User: user, Staff: h.Staff, Reason: "more spam", Date: date})
+	lib.Publish(ctx, h.Bot.Utils, events.NoteEdited{Guild: h.Guild.ID, User: user, Staff: h.Staff, Old: "spam", New: "lots of spam"})
+
+	// they're posted to the log channel
+	want := []string{
+		user.Mention() + " was warned by staff (warning #1): spam",
+		user.Mention() + " was banned by staff: more spam",
+		"staff edited a note about " + user.Mention() + ":\n> spam\nis now:\n> lots of spam",
+	}
+	if posts := h.Posts(h.Channels.Log.ID); !slices.Equal(posts, want) {
+		t.Errorf("log posts = %q, want %q", posts, want)
+	}
+
+	// and counted in the staff member's and month's stats
+	var staff []database.StaffLog
+	if err := h.DB.Find(&staff).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(staff) != 1 || staff[0].Staff != h.Staff.ID || staff[0].Bans != 1 || staff[0].Warns != 1 {
+		t.Errorf("staff stats = %+v, want 1 ban and 1 warn", staff)
+	}
+
+	var months []database.MonthLog
+	if err := h.DB.Find(&months).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(months) != 1 || months[0].Month != "2023-07" || months[0].Bans != 1 || months[0].Warns != 1 {
+		t.Errorf("month stats = %+v, want July with 1 ban and 1 warn", months)
+	}
+}
+
+func TestCloseClosesDatabase(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h.Close()
+
+	if err = h.DB.Exec("SELECT 1").Error; err == nil {
+		t.Error("database is still open")
+	}
+}
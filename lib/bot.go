@@ -1,12 +1,25 @@
 package lib
 
 import (
+	"errors"
+	"fmt"
 	"github.com/bwmarrin/discordgo"
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 	"os"
 	"os/signal"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
+)
+
+const (
+	// readyTimeout is how long to wait for discord to be ready after connecting.
+	readyTimeout = time.Minute
+
+	// shutdownTimeout is how long to wait for in progress work to finish when shutting down.
+	shutdownTimeout = 30 * time.Second
 )
 
 // Bot is a discord bot. It's composed of a set of components - each which performs a specific bot functionality.
@@ -15,60 +28,269 @@ type Bot struct {
 	// the components to run
 	components []Component
 
+	// the components that have been started, in order
+	started []Component
+
+	// the config as it was last loaded, before it was checked - guarded by reloadLock
+	loaded *Config
+
+	// held while the config is being reloaded
+	reloadLock sync.Mutex
+
 	// general utilities
 	*Utils
 }
 
 // Component is the interface that bot components should implement.
+// Components can also implement Starter and Stopper to be told when the bot has connected to discord and when it's
+// shutting down.
 type Component interface {
 	// Setup is called before the bot is started. Register any hooks/perform any initial setup here.
 	// The config/db are ready here, but while the discord client has been created it hasn't connected to discord yet,
 	// so do not make discord API calls.
-	Setup(utils *Utils)
+	// Returning an error aborts startup.
+	Setup(utils *Utils) error
+}
+
+// Starter can be implemented by components that need to do something once the bot has connected to discord, e.g.
+// start background work.
+type Starter interface {
+	// Start is called once the bot has connected to discord, in the same order components were set up.
+	// Returning an error aborts startup.
+	Start() error
+}
+
+// Stopper can be implemented by components that need to clean up when the bot shuts down.
+type Stopper interface {
+	// Stop is called when the bot is shutting down, after in progress work has finished, in the reverse order
+	// components were started. Discord is still connected.
+	Stop() error
+}
+
+// ConfigChecker can be implemented by components that check or adjust the config, e.g. against discord.
+type ConfigChecker interface {
+	// CheckConfig is called once the bot has connected to discord and whenever the config is reloaded, with a copy of
+	// the config before it's used. It can change the config, e.g. to disable features with problems.
+	// Returning an error rejects the config: startup is aborted, or the reload is ignored.
+	CheckConfig(config *Config) error
+}
+
+// Reloader can be implemented by components that need to do something when the config is reloaded, e.g. rebuild
+// something built from it.
+type Reloader interface {
+	// Reload is called after a new config has been swapped in, in the same order components were set up.
+	Reload() error
 }
 
 // NewBot creates a new discord bot.
-func NewBot(components []Component, config *Config, db *gorm.DB, log zerolog.Logger) *Bot {
-	return &Bot{
+func NewBot(components []Component, config *Config, db *gorm.DB, log zerolog.Logger, logs *LogBuffer) *Bot {
+	b := &Bot{
 		components: components,
 
 		Utils: &Utils{
-			Config: config,
-			Log:    log,
-			DB:     db,
+			config:     &atomic.Pointer[Config]{},
+			Log:        log,
+			LogLevel:   zerolog.InfoLevel,
+			Logs:       logs,
+			DB:         db,
+			Bus:        NewBus(),
+			Components: NewComponentRouter(),
+			inFlight:   newInFlight(),
+			panics:     newPanicCounter(),
 		},
 	}
+
+	b.loaded = config
+	b.config.Store(config)
+	b.Utils.refreshConfig = b.refreshConfig
+	return b
 }
 
-// Run runs the bot until ctrl+c is entered.
-func (b *Bot) Run() error {
-	discord, err := discordgo.New("Bot " + b.Config.Token)
+// Run runs the bot until ctrl+c is entered. Work in progress is cancelled and waited for before exiting.
+// If configFile isn't empty, the config is reloaded from it when it changes or SIGHUP is received.
+func (b *Bot) Run(configFile string) error {
+	if b.Config().Token == "" {
+		return errors.New("no bot token is configured - set discord, discord_file or BOUNCER_DISCORD")
+	}
+
+	discord, err := NewDiscordClient(b.Config().Token)
 	if err != nil {
 		return err
 	}
 
-	b.Utils.Discord = discord
-
-	for _, component := range b.components {
-		component.Setup(b.Utils)
+	if b.Config().DryRun {
+		b.Log.Warn().Msg("Running in dry run mode - nothing will be changed in discord.")
+		discord = NewDryRun(discord, b.Log)
 	}
 
-	discord.Identify.Intents = discordgo.IntentsAll
-
-	err = discord.Open()
+	err = b.Start(discord)
 	if err != nil {
 		return err
 	}
 
+	stopWatching := make(chan struct{})
+	if configFile != "" {
+		go b.watchConfig(configFile, stopWatching)
+	}
+
 	b.Log.Info().Msg("Bot is now running. Press CTRL-C to exit.")
 	sc := make(chan os.Signal, 1)
 	signal.Notify(sc, syscall.SIGINT, syscall.SIGTERM, os.Interrupt)
 	<-sc
 
-	err = discord.Close()
+	close(stopWatching)
+	b.Stop()
+
+	return nil
+}
+
+// Start sets up the components, connects to discord using the client, and starts the components once connected.
+func (b *Bot) Start(discord DiscordClient) error {
+	b.Utils.Discord = discord
+
+	b.Components.Setup(b.Utils)
+
+	for _, component := range b.components {
+		err := component.Setup(b.ForComponent(componentName(component)))
+		if err != nil {
+			return fmt.Errorf("failed to set up %T: %w", component, err)
+		}
+	}
+
+	ready := make(chan struct{})
+	discord.AddHandlerOnce(func(_ *discordgo.Session, _ *discordgo.Ready) {
+		close(ready)
+	})
+
+	err := discord.Open()
 	if err != nil {
-		b.Log.Warn().Msgf("failed to close discord connection (this may impact future runs): %s", err)
+		return err
+	}
+
+	select {
+	case <-ready:
+	case <-time.After(readyTimeout):
+		b.close()
+		return fmt.Errorf("didn't receive ready event from discord within %s", readyTimeout)
+	}
+
+	config, err := b.checkConfig(b.Config())
+	if err != nil {
+		b.close()
+		return err
+	}
+	b.config.Store(config)
+
+	for _, component := range b.components {
+		if starter, ok := component.(Starter); ok {
+			err = starter.Start()
+			if err != nil {
+				b.stop()
+				b.close()
+				return fmt.Errorf("failed to start %T: %w", component, err)
+			}
+		}
+
+		b.started = append(b.started, component)
+	}
+
+	return nil
+}
+
+// ReloadConfig checks the config and swaps it in, then tells components it changed. The token can't be changed while
+// the bot is running, and dry run mode is kept as it was.
+func (b *Bot) ReloadConfig(config *Config) error {
+	b.reloadLock.Lock()
+	defer b.reloadLock.Unlock()
+
+	return b.reloadConfig(config)
+}
+
+// refreshConfig checks the config as it was last loaded again, and swaps it in.
+func (b *Bot) refreshConfig() error {
+	b.reloadLock.Lock()
+	defer b.reloadLock.Unlock()
+
+	return b.reloadConfig(b.loaded)
+}
+
+// reloadConfig reloads the config. reloadLock must be held.
+func (b *Bot) reloadConfig(config *Config) error {
+	current := b.Config()
+	if config.Token != current.Token {
+		return errors.New("the bot token can't be changed without restarting")
+	}
+
+	// dry run mode may have been turned on with a flag, so only turning it on from the config is worth a warning
+	if config.DryRun && !current.DryRun {
+		b.Log.Warn().Msg("dry run mode can't be turned on without restarting - ignoring it")
+	}
+	config.DryRun = current.DryRun
+
+	checked, err := b.checkConfig(config)
+	if err != nil {
+		return err
+	}
+	b.loaded = config
+	b.config.Store(checked)
+
+	for _, component := range b.components {
+		if reloader, ok := component.(Reloader); ok {
+			err = reloader.Reload()
+			if err != nil {
+				b.Log.Warn().Err(err).Msgf("failed to reload %T", component)
+			}
+		}
 	}
 
 	return nil
 }
+
+// checkConfig returns a copy of the config, as checked and changed by the components.
+func (b *Bot) checkConfig(config *Config) (*Config, error) {
+	config = config.Copy()
+
+	for _, component := range b.components {
+		if checker, ok := component.(ConfigChecker); ok {
+			err := checker.CheckConfig(config)
+			if err != nil {
+				return nil, fmt.Errorf("%T rejected config: %w", component, err)
+			}
+		}
+	}
+
+	return config, nil
+}
+
+// Stop cancels work in progress and waits for it to finish, stops the components, and disconnects from discord.
+func (b *Bot) Stop() {
+	b.Log.Info().Msg("Shutting down, waiting for in progress work to finish.")
+	if !b.inFlight.drain(shutdownTimeout) {
+		b.Log.Warn().Msgf("in progress work didn't finish within %s, exiting anyway", shutdownTimeout)
+	}
+
+	b.stop()
+	b.close()
+}
+
+// stop stops the started components in reverse order.
+func (b *Bot) stop() {
+	for i := len(b.started) - 1; i >= 0; i-- {
+		if stopper, ok := b.started[i].(Stopper); ok {
+			err := stopper.Stop()
+			if err != nil {
+				b.Log.Warn().Err(err).Msgf("failed to stop %T", b.started[i])
+			}
+		}
+	}
+
+	b.started = nil
+}
+
+// close closes the connection to discord.
+func (b *Bot) close() {
+	err := b.Discord.Close()
+	if err != nil {
+		b.Log.Warn().Msgf("failed to close discord connection (this may impact future runs): %s", err)
+	}
+}
@@ -0,0 +1,160 @@
+package lib_test
+
+import (
+	"context"
+	"errors"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/components"
+	"github.com/danvolchek/bouncer-go/lib/fakediscord"
+	"github.com/rs/zerolog"
+	"golang.org/x/exp/slices"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// recorder is a component which records when it's started and stopped, and can fail to start.
+type recorder struct {
+	name      string
+	events    *[]string
+	failStart bool
+}
+
+func (r *recorder) Setup(*lib.Utils) error { return nil }
+
+func (r *recorder) Start() error {
+	if r.failStart {
+		return errors.New("broken")
+	}
+
+	*r.events = append(*r.events, "start "+r.name)
+	return nil
+}
+
+func (r *recorder) Stop() error {
+	*r.events = append(*r.events, "stop "+r.name)
+	return nil
+}
+
+// blockingCommand is a command which blocks until it's cancelled, then takes a while to finish.
+type blockingCommand struct {
+	started  chan struct{}
+	finished atomic.Bool
+}
+
+func (b *blockingCommand) Name() string                      { return "block" }
+func (b *blockingCommand) RequiresUser() bool                { return false }
+func (b *blockingCommand) Permission() components.Permission { return components.PermissionAdmin }
+func (b *blockingCommand) Description() string               { return "blocks until cancelled" }
+func (b *blockingCommand) Arguments() []components.Argument  { return nil }
+func (b *blockingCommand) Setup(*lib.Utils)                  {}
+
+func (b *blockingCommand) Handle(ctx context.Context, _ *components.CommandDetails, _ *discordgo.Message, _ *lib.Utils) error {
+	close(b.started)
+	<-ctx.Done()
+
+	time.Sleep(50 * time.Millisecond)
+	b.finished.Store(true)
+	return nil
+}
+
+// newBot creates a bot with the components, connected to a fake discord with the home server the config refers to.
+func newBot(t *testing.T, config *lib.Config, comps ...lib.Component) (*lib.Bot, *fakediscord.Discord) {
+	t.Helper()
+
+	fake := fakediscord.New("bot")
+	home := fake.AddConfigured(config)
+
+	db, err := database.New(filepath.Join(t.TempDir(), "bouncer.db"), home.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = database.Close(db) })
+
+	return lib.NewBot(comps, config, db, zerolog.Nop(), lib.NewLogBuffer(10)), fake
+}
+
+func TestStopStopsComponentsInReverse(t *testing.T) {
+	var events []string
+	bot, fake := newBot(t, &lib.Config{Servers: lib.ServerConfig{Home: "1"}},
+		&recorder{name: "a", events: &events},
+		&recorder{name: "b", events: &events},
+		&recorder{name: "c", events: &events},
+	)
+
+	if err := bot.Start(fake); err != nil {
+		t.Fatal(err)
+	}
+	bot.Stop()
+
+	want := []string{"start a", "start b", "start c", "stop c", "stop b", "stop a"}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
+
+func TestFailedStartStopsStartedComponents(t *testing.T) {
+	var events []string
+	bot, fake := newBot(t, &lib.Config{Servers: lib.ServerConfig{Home: "1"}},
+		&recorder{name: "a", events: &events},
+		&recorder{name: "b", events: &events},
+		&recorder{name: "c", events: &events, failStart: true},
+		&recorder{name: "d", events: &events},
+	)
+
+	if err := bot.Start(fake); err == nil {
+		t.Fatal("started with a component that failed to start")
+	}
+
+	// the components started before the failure are stopped, and the ones after it are never started
+	want := []string{"start a", "start b", "stop b", "stop a"}
+	if !slices.Equal(events, want) {
+		t.Errorf("events = %q, want %q", events, want)
+	}
+}
+
+func TestStopWaitsForCommands(t *testing.T) {
+	config := &lib.Config{
+		Prefix:  "$",
+		Servers: lib.ServerConfig{Home: "1"},
+		GuildConfig: lib.GuildConfig{
+			Categories: lib.CategoryConfig{CommandsEnabled: []string{"2"}},
+			Roles:      lib.RoleConfig{Admin: []string{"3"}},
+		},
+	}
+
+	command := &blockingCommand{started: make(chan struct{})}
+	commands, err := components.NewCommands([]components.Command{command})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bot, fake := newBot(t, config, commands)
+	channel := fake.AddChannel("1", "commands", discordgo.ChannelTypeGuildText, "2")
+	staff := fake.AddUser("staff")
+	fake.AddMember("1", staff, "3")
+
+	if err = bot.Start(fake); err != nil {
+		t.Fatal(err)
+	}
+
+	go fake.SendMessage(staff, channel.ID, "$block")
+	<-command.started
+
+	// shutting down cancels the command, and waits for it to finish
+	bot.Stop()
+
+	if !command.finished.Load() {
+		t.Error("stopped before the command finished")
+	}
+
+	// work can't start once the bot has stopped
+	ctx, done := bot.Begin(time.Minute)
+	defer done()
+	if ctx.Err() == nil {
+		t.Error("began work after stopping")
+	}
+}
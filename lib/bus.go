@@ -0,0 +1,74 @@
+package lib
+
+import (
+	"context"
+	"reflect"
+	"sync"
+)
+
+// Bus is a publish/subscribe bus for bot events, e.g. a user being warned (see the events package).
+// Commands publish events, and components subscribe to the ones they care about - so bookkeeping like posting to the
+// log channel or updating stats is done in one place rather than by every command.
+type Bus struct {
+	// guards subscribers
+	lock sync.RWMutex
+
+	// map from event type to subscribers to it, in the order they subscribed
+	subscribers map[reflect.Type][]subscriber
+}
+
+// subscriber is a handler subscribed to an event type.
+type subscriber struct {
+	// utils of the component that subscribed
+	utils *Utils
+
+	// handler, called with an event of the subscribed type
+	handle func(ctx context.Context, event any)
+}
+
+// NewBus creates a bus with no subscribers.
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[reflect.Type][]subscriber)}
+}
+
+// Subscribe registers the handler to be called with every event of type T published on the bus.
+// Subscribe during component setup.
+func Subscribe[T any](utils *Utils, handler func(ctx context.Context, event T)) {
+	b := utils.Bus
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	eventType := reflect.TypeOf((*T)(nil)).Elem()
+	b.subscribers[eventType] = append(b.subscribers[eventType], subscriber{
+		utils: utils,
+		handle: func(ctx context.Context, event any) {
+			handler(ctx, event.(T))
+		},
+	})
+}
+
+// Publish calls every subscriber to events of type T with the event, one at a time in the order they subscribed, and
+// returns once they're all done. A panicking subscriber is logged and doesn't stop the others from being called.
+func Publish[T any](ctx context.Context, utils *Utils, event T) {
+	b := utils.Bus
+
+	eventType := reflect.TypeOf((*T)(nil)).Elem()
+
+	b.lock.RLock()
+	subscribers := b.subscribers[eventType]
+	b.lock.RUnlock()
+
+	utils.Log.Debug().Str("event", eventType.String()).Int("subscribers", len(subscribers)).Msg("publishing event")
+
+	for _, sub := range subscribers {
+		sub.call(ctx, eventType, event)
+	}
+}
+
+// call calls the subscriber, recovering from panics the same way as handlers registered with AddHandler.
+func (s subscriber) call(ctx context.Context, eventType reflect.Type, event any) {
+	defer s.utils.recoverHandler(eventType.String())
+
+	s.handle(ctx, event)
+}
@@ -0,0 +1,57 @@
+package lib
+
+import (
+	"context"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/rs/zerolog"
+	"path/filepath"
+	"testing"
+)
+
+type testEvent struct {
+	value string
+}
+
+type otherEvent struct{}
+
+func TestBus(t *testing.T) {
+	db, err := database.New(filepath.Join(t.TempDir(), "bouncer.db"), "home")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	utils := &Utils{DB: db, Bus: NewBus(), panics: newPanicCounter(), Log: zerolog.Nop()}
+
+	var calls []string
+	Subscribe(utils.ForComponent("first"), func(_ context.Context, event testEvent) {
+		calls = append(calls, "first "+event.value)
+	})
+	Subscribe(utils.ForComponent("panicky"), func(_ context.Context, event testEvent) {
+		panic("oops")
+	})
+	Subscribe(utils.ForComponent("last"), func(_ context.Context, event testEvent) {
+		calls = append(calls, "last "+event.value)
+	})
+	Subscribe(utils.ForComponent("other"), func(_ context.Context, event otherEvent) {
+		calls = append(calls, "other")
+	})
+
+	Publish(context.Background(), utils, testEvent{value: "a"})
+	Publish(context.Background(), utils, testEvent{value: "b"})
+
+	want := []string{"first a", "last a", "first b", "last b"}
+	if len(calls) != len(want) {
+		t.Fatalf("calls = %q, want %q", calls, want)
+	}
+	for i := range want {
+		if calls[i] != want[i] {
+			t.Errorf("calls = %q, want %q", calls, want)
+			break
+		}
+	}
+
+	// a panicking subscriber is counted like a panicking event handler
+	if counts := utils.PanicCounts(); len(counts) != 1 || counts["panicky"] != 2 {
+		t.Errorf("panic counts = %v, want panicky: 2", counts)
+	}
+}
@@ -1,13 +1,19 @@
 package components
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/database"
 	"github.com/danvolchek/bouncer-go/lib"
 	uuid2 "github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 	"regexp"
 	"strings"
+	"time"
 )
 
 //go:generate mockgen -typed -destination mocks/mock_command.go . Command
@@ -33,16 +39,55 @@ type Command interface {
 	// will reply with an error.
 	RequiresUser() bool
 
+	// Permission should return the permission level required to run the command. If the user running the command
+	// doesn't have it, the handler will reply with an error.
+	Permission() Permission
+
+	// Description should return a short description of what the command does, shown when picking a slash command.
+	Description() string
+
+	// Arguments should describe the arguments the command takes, in order, not including the user argument if
+	// RequiresUser returns true. It's used to create the command's slash command.
+	Arguments() []Argument
+
 	// Setup is called before the bot is started, after configs/the db is loaded. Perform initial setup here.
 	// Don't save the utils class.
+	// Commands that build something from the config here can also implement lib.Reloader to rebuild it when the
+	// config is reloaded.
 	Setup(utils *lib.Utils)
 
 	// Handle should perform the action the command does.
-	// It should return a boolean indicating whether the command succeeded - if not the handler will reply with a
-	// UUID to look up the logs. The command should log anything relevant, including errors that happen.
-	Handle(command *CommandDetails, message *discordgo.Message, utils *lib.Utils) bool
+	// ctx is cancelled if the command takes too long or the bot is shutting down - utils.DB already uses it, and it
+	// should be passed to any other long-running calls.
+	// It should return an error if the command failed. Errors caused by how the command was used should be
+	// UserErrors (see BadInput, NotFound and PermissionDenied), and errors from discord rejecting a request can be
+	// returned as is - the handler will reply saying what went wrong. For other errors, the handler will log the error
+	// and reply with a UUID to look up the logs. The command should log anything else relevant.
+	Handle(ctx context.Context, command *CommandDetails, message *discordgo.Message, utils *lib.Utils) error
+}
+
+// RegistryCommand can be implemented by commands that need every command registered with the handler, e.g. to sync
+// slash commands. SetRegistry is called before Setup.
+type RegistryCommand interface {
+	SetRegistry(commands []Command)
+}
+
+// ConfirmableCommand can be implemented by destructive commands to have the user who sent them confirm they
+// want to run them before they're handled.
+type ConfirmableCommand interface {
+	// Confirmation should return a summary of what the command will do, and whether confirmation is needed at all.
+	// ctx is the same as the one Handle is called with.
+	Confirmation(ctx context.Context, command *CommandDetails, utils *lib.Utils) (summary string, needed bool)
 }
 
+const (
+	// confirmationTimeout is how long users have to confirm a command.
+	confirmationTimeout = time.Minute
+
+	// commandTimeout is how long a command has to run, including waiting for confirmation.
+	commandTimeout = 2 * time.Minute
+)
+
 // CommandDetails provides some pre-processed information about a command that was executed for convenience.
 // Full details can be found in the message parameter of Command::Handle.
 type CommandDetails struct {
@@ -54,6 +99,34 @@ type CommandDetails struct {
 
 	// User is first user reference in the command. Will be nil if there are none.
 	User *discordgo.User
+
+	// text is the text the arguments were parsed from
+	text string
+
+	// tokens are the parsed arguments, matching Args
+	tokens []token
+}
+
+// Raw returns the arguments exactly as they were typed, including the original whitespace, quotes and formatting.
+// If a user argument was consumed it isn't included.
+func (c CommandDetails) Raw() string {
+	return c.RawFrom(0)
+}
+
+// RawFrom returns the arguments starting from the argument at index i exactly as they were typed.
+// Useful for commands that take free text after some arguments, e.g. a note or reason.
+func (c CommandDetails) RawFrom(i int) string {
+	if i < 0 || i >= len(c.tokens) {
+		return ""
+	}
+
+	return c.text[c.tokens[i].start:]
+}
+
+// shift removes the first argument.
+func (c *CommandDetails) shift() {
+	c.Args = c.Args[1:]
+	c.tokens = c.tokens[1:]
 }
 
 func (c CommandDetails) ShortString() string {
@@ -82,19 +155,76 @@ func NewCommands(commands []Command) (*Commands, error) {
 		commandMap[name] = command
 	}
 
+	// catch commands discord would reject when syncing them now, rather than when someone runs $sync
+	if _, err := ApplicationCommands(commands); err != nil {
+		return nil, err
+	}
+
 	return &Commands{commands: commandMap}, nil
 }
 
 // Setup is called before the bot is started. Register any hooks/perform any initial setup here.
-func (c *Commands) Setup(utils *lib.Utils) {
+func (c *Commands) Setup(utils *lib.Utils) error {
 	c.Utils = utils
 
+	registry := c.Registered()
+
 	for name, command := range c.commands {
 		c.Log.Debug().Str("command", name).Msg("Registering command")
+
+		if registryCommand, ok := command.(RegistryCommand); ok {
+			registryCommand.SetRegistry(registry)
+		}
+
 		command.Setup(c.NewWithLog(lib.AddString("command", name)))
 	}
+	c.checkPermissions()
+
+	c.AddHandler(c.handleCommand)
+	c.AddHandler(c.handleInteraction)
+
+	return nil
+}
+
+// Registered returns the commands the handler runs, sorted by name.
+func (c *Commands) Registered() []Command {
+	commands := maps.Values(c.commands)
+	slices.SortFunc(commands, func(a, b Command) bool {
+		return a.Name() < b.Name()
+	})
+
+	return commands
+}
+
+// Reload tells commands that implement lib.Reloader that the config changed.
+func (c *Commands) Reload() error {
+	c.checkPermissions()
+
+	var errs []error
+	for name, command := range c.commands {
+		if reloader, ok := command.(lib.Reloader); ok {
+			if err := reloader.Reload(); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	}
 
-	c.Discord.AddHandler(c.handleCommand)
+	return errors.Join(errs...)
+}
+
+// checkPermissions warns about commands requiring a custom permission which has no roles configured in a server.
+func (c *Commands) checkPermissions() {
+	config := c.Config()
+
+	for name, command := range c.commands {
+		if permission := command.Permission(); permission != PermissionAdmin && permission != PermissionOwner {
+			for _, guildID := range config.GuildIDs() {
+				if _, ok := config.Guild(guildID).Roles.Permissions[string(permission)]; !ok {
+					c.Log.Warn().Str("command", name).Str("permission", string(permission)).Str("guild", guildID).Msg("command requires a permission with no roles configured - only owners will be able to run it")
+				}
+			}
+		}
+	}
 }
 
 // handleCommand is called on every new message being sent and runs the appropriate command based on the message text.
@@ -105,6 +235,7 @@ func (c *Commands) handleCommand(_ *discordgo.Session, messageCreate *discordgo.
 	invoker := commandInvoker{
 		uuid:     uuid,
 		message:  messageCreate.Message,
+		source:   messageSource{message: messageCreate.Message, prefix: c.Config().Prefix},
 		commands: c.commands,
 		Utils:    c.Utils.NewWithLog(lib.AddString("uuid", uuid)),
 	}
@@ -112,6 +243,59 @@ func (c *Commands) handleCommand(_ *discordgo.Session, messageCreate *discordgo.
 	invoker.invoke()
 }
 
+// commandSource is what a command was sent through - e.g. a message or a slash command.
+type commandSource interface {
+	// parse parses the command that was sent
+	parse() (*CommandDetails, error)
+
+	// ignored is called when the command is ignored, e.g. because it was sent by a non-staff user
+	ignored()
+
+	// finished is called after the command is handled with how it went, whether it succeeded or not
+	finished(result database.CommandResult)
+}
+
+// messageSource is a command sent as a prefixed message.
+type messageSource struct {
+	// message including the command name + args
+	message *discordgo.Message
+
+	// command prefix
+	prefix string
+}
+
+// parse parses the raw message text into a command
+func (m messageSource) parse() (*CommandDetails, error) {
+	// Strip prefix from message (it must be present because of the check to ignore messages without it)
+	messageContent := strings.TrimSpace(m.message.Content)[len(m.prefix):]
+
+	tokens, err := tokenize(messageContent)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return &CommandDetails{}, nil
+	}
+
+	// The first token is the command name, the rest are args
+	details := &CommandDetails{
+		Name:   tokens[0].value,
+		text:   messageContent,
+		tokens: tokens[1:],
+	}
+
+	for _, arg := range details.tokens {
+		details.Args = append(details.Args, arg.value)
+	}
+
+	return details, nil
+}
+
+func (m messageSource) ignored() {}
+
+func (m messageSource) finished(database.CommandResult) {}
+
 // commandInvoker invokes a command once.
 type commandInvoker struct {
 	// uuid to trace execution of this command
@@ -120,6 +304,9 @@ type commandInvoker struct {
 	// message including the command name + args
 	message *discordgo.Message
 
+	// what the command was sent through
+	source commandSource
+
 	// commands to lookup implementation from
 	commands map[string]Command
 
@@ -130,35 +317,70 @@ type commandInvoker struct {
 // invoke invokes the command
 func (c commandInvoker) invoke() {
 	if c.shouldIgnoreMessage() {
+		c.source.ignored()
 		return
 	}
 
-	if c.Log.Debug().Enabled() {
+	record := &database.CommandLog{
+		UUID:    c.uuid,
+		GuildId: c.message.GuildID,
+		StaffId: c.message.Author.ID,
+		Staff:   c.message.Author.Username,
+		Channel: c.message.ChannelID,
+		Text:    c.message.Content,
+		Date:    time.Now(),
+	}
+
+	ctx, done := c.Begin(commandTimeout)
+	defer done()
+
+	// deferred after done so it runs first - the source's last reply is part of the work being waited on
+	defer func() { c.source.finished(record.Result) }()
+
+	record.Result = c.run(ctx, record)
+	record.Duration = time.Since(record.Date)
+
+	if err := c.DB.Create(record).Error; err != nil {
+		c.Log.Error().Err(err).Msg("failed to record command in audit log")
+	}
+}
+
+// run parses and runs the command, returning the result. The parsed command name is set in record.
+func (c commandInvoker) run(ctx context.Context, record *database.CommandLog) (result database.CommandResult) {
+	if c.LogLevel <= zerolog.DebugLevel {
 		c.sendUUID(false)
 	}
 
-	commandDetails, err := c.parseCommand()
+	commandDetails, err := c.source.parse()
 	if err != nil {
-		c.Log.Error().Err(err).Msg("failed to parse message")
-		c.sendUUID(true)
-		return
+		c.Log.Debug().Err(err).Msg("failed to parse message")
+		c.Reply(c.message, fmt.Sprintf("Couldn't understand that command: %s.", err))
+		return database.CommandRejected
 	}
 
+	record.Command = commandDetails.Name
+
 	command, ok := c.commands[commandDetails.Name]
 	if !ok {
 		c.Log.Debug().Str("name", commandDetails.Name).Msg("no command exists with this name")
-		c.Reply(c.message, fmt.Sprintf("Unknown command `%s` - see `%shelp`", commandDetails.Name, c.Config.Prefix))
-		return
+		c.Reply(c.message, fmt.Sprintf("Unknown command `%s` - see `%shelp`", commandDetails.Name, c.Config().Prefix))
+		return database.CommandRejected
 	}
 
 	c.Utils = c.NewWithLog(lib.AddString("command", commandDetails.ShortString()))
 
+	if permission := command.Permission(); !c.hasPermission(permission) {
+		c.Log.Warn().Str("permission", string(permission)).Msg("user doesn't have permission to run command")
+		c.Reply(c.message, fmt.Sprintf("Sorry, you need the `%s` permission to use `%s%s`.", permission, c.Config().Prefix, commandDetails.Name))
+		return database.CommandRejected
+	}
+
 	if command.RequiresUser() {
 		ok := c.setUser(commandDetails)
 		if !ok {
 			c.Log.Warn().Msg("user not found, but one is required")
-			c.Reply(c.message, fmt.Sprintf("This command requires a valid user - see `%shelp`", c.Config.Prefix))
-			return
+			c.Reply(c.message, fmt.Sprintf("This command requires a valid user - see `%shelp`", c.Config().Prefix))
+			return database.CommandRejected
 		}
 	}
 
@@ -166,14 +388,48 @@ func (c commandInvoker) invoke() {
 		if r := recover(); r != nil {
 			c.Log.Error().Any("panic", r).Msg("command panicked")
 			c.sendUUID(true)
+			result = database.CommandPanicked
 		}
 	}()
 
-	ok = command.Handle(commandDetails, c.message, c.Utils)
-	if !ok {
-		c.Log.Warn().Msg("command failed")
-		c.sendUUID(true)
+	if confirmable, isConfirmable := command.(ConfirmableCommand); isConfirmable {
+		if summary, needed := confirmable.Confirmation(ctx, commandDetails, c.Utils); needed {
+			confirmed, err := c.Confirm(ctx, c.message, c.message.Author.ID, summary, confirmationTimeout)
+			if err != nil {
+				c.Log.Error().Err(err).Msg("failed to ask for confirmation")
+				c.sendUUID(true)
+				return database.CommandFailed
+			}
+
+			if !confirmed {
+				c.Log.Debug().Msg("command wasn't confirmed")
+				return database.CommandRejected
+			}
+		}
+	}
+
+	c.DB = c.DB.WithContext(ctx)
+
+	err = command.Handle(ctx, commandDetails, c.message, c.Utils)
+	if err == nil {
+		return database.CommandSucceeded
 	}
+
+	if reply, ok := userFacingMessage(err, c.Config().Prefix); ok {
+		c.Log.Info().Err(err).Msg("command couldn't be run as requested")
+		c.Reply(c.message, reply)
+
+		var userErr *UserError
+		if errors.As(err, &userErr) {
+			return database.CommandRejected
+		}
+
+		return database.CommandFailed
+	}
+
+	c.Log.Error().Err(err).Msg("command failed")
+	c.sendUUID(true)
+	return database.CommandFailed
 }
 
 // sendUUID sends a discord message with the invoker's uuid
@@ -181,7 +437,7 @@ func (c commandInvoker) sendUUID(wasError bool) {
 	if !wasError {
 		c.Reply(c.message, fmt.Sprintf("UUID for logs is `%s`.", c.uuid))
 	} else {
-		c.Reply(c.message, fmt.Sprintf("Oops, something went wrong handling that message. Check the logs for `%s`.", c.uuid))
+		c.Reply(c.message, fmt.Sprintf("Oops, something went wrong handling that message. Check the logs with `%slogs %s`.", c.Config().Prefix, c.uuid))
 	}
 }
 
@@ -189,19 +445,26 @@ func (c commandInvoker) sendUUID(wasError bool) {
 // any errors should be logged but not sent to the user who sent the message because that user may not be an admin
 func (c commandInvoker) shouldIgnoreMessage() bool {
 	// Ignore messages sent by this user or other bots
-	if c.message.Author.ID == c.Discord.State.User.ID || c.message.Author.Bot {
+	if c.message.Author.ID == c.Discord.BotUser().ID || c.message.Author.Bot {
+		return true
+	}
+
+	// Ignore messages in servers the bot isn't configured for
+	guild := c.Config().Guild(c.message.GuildID)
+	if guild == nil {
+		c.Log.Trace().Str("guild", c.message.GuildID).Msg("ignoring message in unconfigured server")
 		return true
 	}
 
 	// Ignore messages in non-enabled channels
 	{
-		channel, err := c.Discord.State.Channel(c.message.ChannelID)
+		channel, err := c.Discord.Channel(c.message.ChannelID)
 		if err != nil {
 			c.Log.Error().Err(err).Msg("ignoring message - failed to retrieve channel info")
 			return true
 		}
 
-		if !slices.Contains(c.Config.Categories.CommandsEnabled, channel.ParentID) {
+		if !slices.Contains(guild.Categories.CommandsEnabled, channel.ParentID) {
 			c.Log.Trace().
 				Str("category", channel.ParentID).
 				Str("channel", c.message.ChannelID).
@@ -210,25 +473,22 @@ func (c commandInvoker) shouldIgnoreMessage() bool {
 		}
 	}
 
-	// Ignore messages from non-admin users
+	// Ignore messages from non-staff users
 	{
-		user, err := c.Discord.State.Member(c.message.GuildID, c.message.Author.ID)
+		member, err := c.member()
 		if err != nil {
 			c.Log.Error().Err(err).Msg("ignoring message - failed to retrieve member info")
 			return true
 		}
 
-		if !slices.ContainsFunc(c.Config.Roles.Admin, func(adminRole string) bool {
-			return slices.Contains(user.Roles, adminRole)
-		}) {
-			c.Log.Debug().Str("user", c.message.Author.Username).Msg("ignoring message from non-admin user")
+		if !isStaff(c.Config(), guild, member) {
+			c.Log.Debug().Str("user", c.message.Author.Username).Msg("ignoring message from non-staff user")
 			return true
 		}
-
 	}
 
 	// Ignore messages without bot prefix
-	if !strings.HasPrefix(strings.TrimSpace(c.message.Content), c.Config.Prefix) {
+	if !strings.HasPrefix(strings.TrimSpace(c.message.Content), c.Config().Prefix) {
 		c.Log.Debug().Msg("ignoring message without bot prefix")
 		return true
 	}
@@ -236,34 +496,26 @@ func (c commandInvoker) shouldIgnoreMessage() bool {
 	return false
 }
 
-// parseCommand parses the raw message text into a command
-func (c commandInvoker) parseCommand() (*CommandDetails, error) {
-	// Strip prefix from message (it must be present because of the above check to ignore messages without it)
-	messageContent := strings.TrimSpace(c.message.Content)[len(c.Config.Prefix):]
+// member returns the guild member who sent the message
+func (c commandInvoker) member() (*discordgo.Member, error) {
+	return c.Discord.GuildMember(c.message.GuildID, c.message.Author.ID)
+}
 
-	// Parse command name and args out of the message
-	commandName, rawArgs, ok := strings.Cut(messageContent, " ")
-	if !ok {
-		// If there are no spaces, the command has no args
-		return &CommandDetails{
-			Name: messageContent,
-			Args: nil,
-		}, nil
+// hasPermission returns whether the user who sent the message has the permission in the server it was sent in
+func (c commandInvoker) hasPermission(permission Permission) bool {
+	config := c.Config()
+	guild := config.Guild(c.message.GuildID)
+	if guild == nil {
+		return false
 	}
 
-	// Trim spaces in commands to allow for extra spaces
-	commandName = strings.TrimSpace(commandName)
-
-	// Split args into components and also trim them
-	commandArgs := strings.Split(rawArgs, " ")
-	for i, arg := range commandArgs {
-		commandArgs[i] = strings.TrimSpace(arg)
+	member, err := c.member()
+	if err != nil {
+		c.Log.Error().Err(err).Msg("failed to retrieve member info")
+		return false
 	}
 
-	return &CommandDetails{
-		Name: commandName,
-		Args: commandArgs,
-	}, nil
+	return hasPermission(config, guild, member, permission)
 }
 
 // setUser updates command details with a user, returning whether it was successful
@@ -273,7 +525,7 @@ func (c commandInvoker) setUser(command *CommandDetails) bool {
 
 	if user != nil {
 		// If found, update args and user
-		command.Args = command.Args[1:]
+		command.shift()
 		command.User = user
 
 		return true
@@ -0,0 +1,16 @@
+package components
+
+import (
+	"github.com/danvolchek/bouncer-go/lib"
+)
+
+// All returns every component the bot runs, in the order they should be set up, handling the provided commands.
+// The syslog component is passed in because it's also a log output, so it has to exist before the logger does.
+func All(commands []Command, sysLog *SysLog) ([]lib.Component, error) {
+	commandHandler, err := NewCommands(commands)
+	if err != nil {
+		return nil, err
+	}
+
+	return []lib.Component{NewOverrides(), NewReady(), sysLog, commandHandler, NewStats(), NewLogChannel(), NewSharedBans()}, nil
+}
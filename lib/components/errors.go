@@ -0,0 +1,82 @@
+package components
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"net/http"
+)
+
+// UserError is an error caused by how a command was used rather than by something going wrong in the bot, e.g. a
+// typo. Its message is shown to the user who ran the command instead of a UUID to look up the logs.
+type UserError struct {
+	// Kind is what sort of problem it is.
+	Kind UserErrorKind
+
+	// Message describes the problem to the user.
+	Message string
+}
+
+func (u *UserError) Error() string {
+	return u.Message
+}
+
+// UserErrorKind is a sort of user error.
+type UserErrorKind int
+
+const (
+	// ErrBadInput means the command's arguments were invalid.
+	ErrBadInput UserErrorKind = iota
+
+	// ErrNotFound means something the command refers to doesn't exist.
+	ErrNotFound
+
+	// ErrPermissionDenied means the user isn't allowed to do what the command would do.
+	ErrPermissionDenied
+)
+
+// BadInput returns an error indicating the command's arguments were invalid.
+func BadInput(format string, args ...any) error {
+	return &UserError{Kind: ErrBadInput, Message: fmt.Sprintf(format, args...)}
+}
+
+// NotFound returns an error indicating something the command refers to doesn't exist.
+func NotFound(format string, args ...any) error {
+	return &UserError{Kind: ErrNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+// PermissionDenied returns an error indicating the user isn't allowed to do what the command would do.
+func PermissionDenied(format string, args ...any) error {
+	return &UserError{Kind: ErrPermissionDenied, Message: fmt.Sprintf(format, args...)}
+}
+
+// userFacingMessage returns the message to show the user for the error, and whether it should be shown at all.
+// Errors that aren't the user's fault aren't shown - the user is given a UUID to look up the logs instead.
+func userFacingMessage(err error, prefix string) (string, bool) {
+	var userErr *UserError
+	if errors.As(err, &userErr) {
+		switch userErr.Kind {
+		case ErrBadInput:
+			return fmt.Sprintf("%s - see `%shelp`.", userErr.Message, prefix), true
+		case ErrNotFound:
+			return fmt.Sprintf("%s.", userErr.Message), true
+		case ErrPermissionDenied:
+			return fmt.Sprintf("Sorry, %s.", userErr.Message), true
+		}
+	}
+
+	// discord rejecting a request (e.g. the user doesn't exist, or the bot's missing permissions) is caused by what
+	// the command was asked to do, not a bug, so say why
+	var restErr *discordgo.RESTError
+	if errors.As(err, &restErr) && restErr.Response != nil && restErr.Response.StatusCode >= 400 && restErr.Response.StatusCode < 500 &&
+		restErr.Response.StatusCode != http.StatusTooManyRequests {
+		reason := http.StatusText(restErr.Response.StatusCode)
+		if restErr.Message != nil && restErr.Message.Message != "" {
+			reason = restErr.Message.Message
+		}
+
+		return fmt.Sprintf("Discord rejected that request: %s.", reason), true
+	}
+
+	return "", false
+}
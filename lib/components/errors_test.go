@@ -0,0 +1,129 @@
+package components
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/fakediscord"
+	"github.com/rs/zerolog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// restError returns an error like the one discordgo returns when discord responds to a request with the status.
+func restError(status int, message string) error {
+	err := &discordgo.RESTError{Response: &http.Response{StatusCode: status}}
+	if message != "" {
+		err.Message = &discordgo.APIErrorMessage{Message: message}
+	}
+
+	return err
+}
+
+func TestUserFacingMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+		show bool
+	}{
+		{"bad input", BadInput("%q isn't a number", "x"), "\"x\" isn't a number - see `$help`.", true},
+		{"not found", NotFound("user %s doesn't have a warning 3", "2"), "user 2 doesn't have a warning 3.", true},
+		{"permission denied", PermissionDenied("only admins can do that"), "Sorry, only admins can do that.", true},
+		{"wrapped", fmt.Errorf("adding entry: %w", NotFound("no such user")), "no such user.", true},
+		{"unknown kind", &UserError{Kind: -1, Message: "huh"}, "", false},
+		{"discord rejected", restError(http.StatusForbidden, "Missing Permissions"), "Discord rejected that request: Missing Permissions.", true},
+		{"discord rejected without a message", restError(http.StatusNotFound, ""), "Discord rejected that request: Not Found.", true},
+		{"rate limited", restError(http.StatusTooManyRequests, "You are being rate limited."), "", false},
+		{"discord failed", restError(http.StatusInternalServerError, "Internal Server Error"), "", false},
+		{"internal", errors.New("database is locked"), "", false},
+		{"cancelled", context.Canceled, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, show := userFacingMessage(test.err, "$")
+			if got != test.want || show != test.show {
+				t.Errorf("userFacingMessage(%v) = %q, %t, want %q, %t", test.err, got, show, test.want, test.show)
+			}
+		})
+	}
+}
+
+// failingCommand is a command which fails with an error.
+type failingCommand struct {
+	testCommand
+	err error
+}
+
+func (f *failingCommand) Handle(context.Context, *CommandDetails, *discordgo.Message, *lib.Utils) error {
+	return f.err
+}
+
+func TestCommandErrorReplies(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		want    string
+		notWant string
+	}{
+		{"user error", BadInput("that's not a user"), "that's not a user - see `$help`.", ""},
+		{"internal error", errors.New("database is locked"), "Oops, something went wrong handling that message. Check the logs with `$logs ", "database is locked"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake := fakediscord.New("bot")
+			guild := fake.AddGuild("guild")
+			admin := fake.AddRole(guild.ID, "admin", discordgo.PermissionAdministrator)
+			category := fake.AddChannel(guild.ID, "staff", discordgo.ChannelTypeGuildCategory, "")
+			channel := fake.AddChannel(guild.ID, "commands", discordgo.ChannelTypeGuildText, category.ID)
+			user := fake.AddUser("staff")
+			fake.AddMember(guild.ID, user, admin.ID)
+
+			config := &lib.Config{
+				Prefix:  "$",
+				Servers: lib.ServerConfig{Home: guild.ID},
+				GuildConfig: lib.GuildConfig{
+					Categories: lib.CategoryConfig{CommandsEnabled: []string{category.ID}},
+					Roles:      lib.RoleConfig{Admin: []string{admin.ID}},
+				},
+			}
+
+			db, err := database.New(filepath.Join(t.TempDir(), "bouncer.db"), guild.ID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer database.Close(db)
+
+			commands, err := NewCommands([]Command{&failingCommand{testCommand: testCommand{name: "fail"}, err: test.err}})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			bot := lib.NewBot([]lib.Component{commands}, config, db, zerolog.Nop(), lib.NewLogBuffer(10))
+			if err = bot.Start(fake); err != nil {
+				t.Fatal(err)
+			}
+			defer bot.Stop()
+
+			fake.SendMessage(user, channel.ID, "$fail")
+
+			// internal errors are only replied to with where to find the logs, not described
+			messages := fake.Messages(channel.ID)
+			if len(messages) != 2 {
+				t.Fatalf("%d messages sent, want the command and one reply", len(messages))
+			}
+
+			reply := messages[1].Content
+			if !strings.HasPrefix(reply, test.want) || (test.notWant != "" && strings.Contains(reply, test.notWant)) {
+				t.Errorf("reply = %q, want it to start with %q", reply, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/events"
+)
+
+// LogChannel is a component that posts moderation events to the log channel of the server they happened in, so staff
+// have a record of them. Config changes are posted to the home server's.
+type LogChannel struct {
+	*lib.Utils
+}
+
+// NewLogChannel creates a log channel component.
+func NewLogChannel() *LogChannel {
+	return &LogChannel{}
+}
+
+// Setup subscribes to moderation events.
+func (l *LogChannel) Setup(utils *lib.Utils) error {
+	l.Utils = utils
+
+	lib.Subscribe(utils, l.userWarned)
+	lib.Subscribe(utils, l.userBanned)
+	lib.Subscribe(utils, l.noteEdited)
+	lib.Subscribe(utils, l.configChanged)
+
+	return nil
+}
+
+func (l *LogChannel) userWarned(ctx context.Context, event events.UserWarned) {
+	l.post(ctx, event.Guild, fmt.Sprintf("%s was warned by %s (warning #%d): %s", event.User.Mention(), event.Staff.Username, event.Count, event.Reason))
+}
+
+func (l *LogChannel) userBanned(ctx context.Context, event events.UserBanned) {
+	l.post(ctx, event.Guild, fmt.Sprintf("%s was banned by %s: %s", event.User.Mention(), event.Staff.Username, event.Reason))
+}
+
+func (l *LogChannel) noteEdited(ctx context.Context, event events.NoteEdited) {
+	l.post(ctx, event.Guild, fmt.Sprintf("%s edited a note about %s:\n> %s\nis now:\n> %s", event.Staff.Username, event.User.Mention(), event.Old, event.New))
+}
+
+func (l *LogChannel) configChanged(ctx context.Context, event events.ConfigChanged) {
+	verb := "set"
+	if event.Reset {
+		verb = "reset"
+	}
+
+	format := func(value string) string {
+		if value == "" {
+			return "nothing"
+		}
+		return "`" + value + "`"
+	}
+
+	l.post(ctx, l.Config().Servers.Home, fmt.Sprintf("%s %s config `%s` from %s to %s", event.Staff.Username, verb, event.Key, format(event.Old), format(event.New)))
+}
+
+// post sends the message to the server's log channel.
+func (l *LogChannel) post(ctx context.Context, guildID string, message string) {
+	guild := l.Config().Guild(guildID)
+	if guild == nil || guild.Channels.Log == "" {
+		return
+	}
+
+	_, err := l.Discord.ChannelMessageSend(guild.Channels.Log, message, discordgo.WithContext(ctx))
+	if err != nil {
+		l.Log.Error().Err(err).Msg("failed to post to log channel")
+	}
+}
@@ -0,0 +1,44 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"github.com/danvolchek/bouncer-go/lib"
+)
+
+// Overrides is a component that applies config fields set with the config command on top of the config file, whenever
+// the config is checked. It must come before components that check the config, so they check the overridden values.
+type Overrides struct {
+	*lib.Utils
+}
+
+// NewOverrides creates a config overrides component.
+func NewOverrides() *Overrides {
+	return &Overrides{}
+}
+
+func (o *Overrides) Setup(utils *lib.Utils) error {
+	o.Utils = utils
+
+	return nil
+}
+
+// CheckConfig applies the overrides to the config. Overrides that no longer apply, e.g. because the field was removed,
+// are logged and skipped.
+func (o *Overrides) CheckConfig(config *lib.Config) error {
+	overrides, err := o.Repo().ConfigOverrides(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to load config overrides: %w", err)
+	}
+
+	for _, override := range overrides {
+		if err := config.Set(override.Key, override.Value); err != nil {
+			o.Log.Warn().Err(err).Str("key", override.Key).Msg("ignoring config override")
+			continue
+		}
+
+		o.Log.Debug().Msgf("Config %s overridden with the config command", override.Key)
+	}
+
+	return nil
+}
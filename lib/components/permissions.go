@@ -0,0 +1,66 @@
+package components
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"golang.org/x/exp/slices"
+)
+
+// Permission is the permission level required to run a command.
+// PermissionAdmin and PermissionOwner are built in - any other value refers to a named set of roles in
+// RoleConfig.Permissions.
+type Permission string
+
+const (
+	// PermissionAdmin allows users with an admin role (or owners) to run a command.
+	PermissionAdmin Permission = "admin"
+
+	// PermissionOwner allows only bot owners to run a command. Owners are either listed in UserConfig.Owners or have an
+	// owner role.
+	PermissionOwner Permission = "owner"
+)
+
+// isOwner returns whether the member of the server with the config is a bot owner.
+func isOwner(config *lib.Config, guild *lib.GuildConfig, member *discordgo.Member) bool {
+	return slices.Contains(config.Users.Owners, member.User.ID) || hasAnyRole(member, guild.Roles.Owner)
+}
+
+// hasPermission returns whether the member of the server with the config is allowed to run commands requiring the
+// permission. Owners have every permission.
+func hasPermission(config *lib.Config, guild *lib.GuildConfig, member *discordgo.Member, permission Permission) bool {
+	if isOwner(config, guild, member) {
+		return true
+	}
+
+	switch permission {
+	case PermissionOwner:
+		return false
+	case PermissionAdmin:
+		return hasAnyRole(member, guild.Roles.Admin)
+	default:
+		return hasAnyRole(member, guild.Roles.Permissions[string(permission)])
+	}
+}
+
+// isStaff returns whether the member of the server with the config has any permission at all, i.e. whether they can
+// run at least some commands.
+func isStaff(config *lib.Config, guild *lib.GuildConfig, member *discordgo.Member) bool {
+	if isOwner(config, guild, member) || hasAnyRole(member, guild.Roles.Admin) {
+		return true
+	}
+
+	for _, roles := range guild.Roles.Permissions {
+		if hasAnyRole(member, roles) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasAnyRole returns whether the member has at least one of the roles.
+func hasAnyRole(member *discordgo.Member, roles []string) bool {
+	return slices.ContainsFunc(roles, func(role string) bool {
+		return slices.Contains(member.Roles, role)
+	})
+}
@@ -1,10 +1,14 @@
 package components
 
 import (
+	"fmt"
 	"github.com/bwmarrin/discordgo"
 	"github.com/danvolchek/bouncer-go/lib"
 )
 
+// Ready is a component that reports when the bot connects, and checks the config against the home server once it
+// has and whenever it's reloaded. Features with config problems are disabled, and the bot refuses to start (or the
+// reload is ignored) if it can't run at all.
 type Ready struct {
 	*lib.Utils
 }
@@ -13,10 +17,32 @@ func NewReady() *Ready {
 	return &Ready{}
 }
 
-func (r *Ready) Setup(utils *lib.Utils) {
+func (r *Ready) Setup(utils *lib.Utils) error {
 	r.Utils = utils
 
-	r.Discord.AddHandler(r.ready)
+	r.AddHandler(r.ready)
+
+	return nil
+}
+
+// CheckConfig checks the config, printing a report.
+func (r *Ready) CheckConfig(config *lib.Config) error {
+	report, err := validateConfig(r.Utils, config)
+
+	switch {
+	case err != nil:
+		r.Log.Error().Msg(report.String())
+	case report.hasProblems():
+		r.Log.Warn().Msg(report.String())
+	default:
+		r.Log.Info().Msg(report.String())
+	}
+
+	if err != nil {
+		return fmt.Errorf("config check failed: %w", err)
+	}
+
+	return nil
 }
 
 func (r *Ready) ready(_ *discordgo.Session, _ *discordgo.Ready) {
@@ -0,0 +1,93 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"os"
+	"sync"
+	"time"
+)
+
+// RecordedEvent is a gateway event as written by Recorder, one per line.
+type RecordedEvent struct {
+	// Time is when the bot received the event.
+	Time time.Time `json:"time"`
+
+	// Type is the gateway event type, e.g. MESSAGE_CREATE.
+	Type string `json:"type"`
+
+	// Data is the event as discord sent it.
+	Data json.RawMessage `json:"data"`
+}
+
+// Recorder is a component that writes every gateway event the bot receives to a file, so problems can be reproduced
+// offline by replaying them with -replay.
+type Recorder struct {
+	// path of the file to write to
+	path string
+
+	// guards file and encoder
+	lock sync.Mutex
+
+	// the file events are written to
+	file *os.File
+
+	// encodes events to the file
+	encoder *json.Encoder
+
+	*lib.Utils
+}
+
+// NewRecorder creates a recorder which appends events to the file at the path.
+func NewRecorder(path string) *Recorder {
+	return &Recorder{path: path}
+}
+
+// Setup opens the file and starts recording.
+func (r *Recorder) Setup(utils *lib.Utils) error {
+	r.Utils = utils
+
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open event recording: %w", err)
+	}
+
+	r.file = file
+	r.encoder = json.NewEncoder(file)
+
+	r.AddHandler(r.record)
+
+	r.Log.Info().Str("path", r.path).Msg("recording gateway events")
+
+	return nil
+}
+
+// Stop closes the file.
+func (r *Recorder) Stop() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *Recorder) record(_ *discordgo.Session, event *discordgo.Event) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.file == nil {
+		return
+	}
+
+	err := r.encoder.Encode(RecordedEvent{
+		Time: time.Now(),
+		Type: event.Type,
+		Data: event.RawData,
+	})
+	if err != nil {
+		r.Log.Warn().Err(err).Str("type", event.Type).Msg("failed to record gateway event")
+	}
+}
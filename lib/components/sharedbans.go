@@ -0,0 +1,77 @@
+package components
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"time"
+)
+
+// sharedBanTimeout is how long sharing a ban with the other servers can take.
+const sharedBanTimeout = time.Minute
+
+// SharedBans is a component that bans users from every server sharing bans when they're banned from one of them, by
+// anyone - not just with the bot. Unbans aren't shared, since appeals are handled per server.
+type SharedBans struct {
+	*lib.Utils
+}
+
+// NewSharedBans creates a shared bans component.
+func NewSharedBans() *SharedBans {
+	return &SharedBans{}
+}
+
+func (s *SharedBans) Setup(utils *lib.Utils) error {
+	s.Utils = utils
+
+	s.AddHandler(s.guildBanAdd)
+
+	return nil
+}
+
+func (s *SharedBans) guildBanAdd(_ *discordgo.Session, event *discordgo.GuildBanAdd) {
+	config := s.Config()
+
+	from := config.Guild(event.GuildID)
+	if from == nil || !from.SharedBans {
+		return
+	}
+
+	ctx, done := s.Begin(sharedBanTimeout)
+	defer done()
+
+	log := s.Log.With().Str("guild", event.GuildID).Str("user", event.User.ID).Logger()
+
+	// the ban event doesn't include the reason
+	reason := "no reason given"
+	ban, err := s.Discord.GuildBan(event.GuildID, event.User.ID, discordgo.WithContext(ctx))
+	if err != nil {
+		log.Warn().Err(err).Msg("failed to get reason for shared ban")
+	} else if ban.Reason != "" {
+		reason = ban.Reason
+	}
+
+	name := event.GuildID
+	if guild, err := s.Discord.Guild(event.GuildID); err == nil {
+		name = guild.Name
+	}
+
+	for _, guildID := range config.GuildIDs() {
+		if guildID == event.GuildID || !config.Guild(guildID).SharedBans {
+			continue
+		}
+
+		// this is also how bans made here don't bounce back: the ban event from each server finds the user already banned
+		if _, err = s.Discord.GuildBan(guildID, event.User.ID, discordgo.WithContext(ctx)); err == nil {
+			continue
+		}
+
+		err = s.Discord.GuildBanCreateWithReason(guildID, event.User.ID, fmt.Sprintf("Shared ban from %s: %s", name, reason), 0, discordgo.WithContext(ctx))
+		if err != nil {
+			log.Error().Err(err).Str("to", guildID).Msg("failed to share ban")
+			continue
+		}
+
+		log.Info().Str("to", guildID).Msg("Shared ban")
+	}
+}
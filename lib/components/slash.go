@@ -0,0 +1,457 @@
+package components
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/lib"
+	uuid2 "github.com/google/uuid"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Argument describes an argument a command takes. It's used to build the command's slash command.
+type Argument struct {
+	// Name is the name of the argument. Must be lowercase.
+	Name string
+
+	// Description is a short description of the argument.
+	Description string
+
+	// Type is the type of the argument.
+	Type ArgumentType
+
+	// Required is whether the argument must be provided.
+	Required bool
+
+	// Choices, if not empty, are the only values the argument can be.
+	Choices []string
+
+	// Autocomplete, if not nil, returns suggested values for the argument given what's been typed so far. Discord doesn't
+	// allow it together with Choices.
+	Autocomplete func(utils *lib.Utils, partial string) []string
+}
+
+// ArgumentType is the type of argument.
+type ArgumentType int
+
+const (
+	// ArgumentString is a single word, or a quoted string.
+	ArgumentString ArgumentType = iota
+
+	// ArgumentText is free text that takes up the rest of the command. Commands should read it using
+	// CommandDetails.RawFrom so the original formatting is kept.
+	ArgumentText
+
+	// ArgumentInteger is a whole number.
+	ArgumentInteger
+
+	// ArgumentUser is a user reference.
+	ArgumentUser
+
+	// ArgumentChannel is a channel reference.
+	ArgumentChannel
+)
+
+// EphemeralCommand can be implemented by commands whose slash command replies should only be visible to the user who
+// ran them.
+type EphemeralCommand interface {
+	Ephemeral() bool
+}
+
+// userArgument is the argument added to slash commands that require a user.
+var userArgument = Argument{
+	Name:        "user",
+	Description: "The user to run the command on",
+	Type:        ArgumentUser,
+	Required:    true,
+}
+
+// ApplicationCommands returns the slash commands for the provided commands. It returns an error if a command has an
+// argument discord would reject.
+func ApplicationCommands(commands []Command) ([]*discordgo.ApplicationCommand, error) {
+	dmPermission := false
+
+	var appCommands []*discordgo.ApplicationCommand
+	for _, command := range commands {
+		appCommand := &discordgo.ApplicationCommand{
+			Type:         discordgo.ChatApplicationCommand,
+			Name:         command.Name(),
+			Description:  command.Description(),
+			DMPermission: &dmPermission,
+		}
+
+		for _, argument := range slashArguments(command) {
+			if len(argument.Choices) != 0 && argument.Autocomplete != nil {
+				return nil, fmt.Errorf("command '%s' argument '%s' has both choices and autocomplete", command.Name(), argument.Name)
+			}
+
+			option := &discordgo.ApplicationCommandOption{
+				Name:         argument.Name,
+				Description:  argument.Description,
+				Required:     argument.Required,
+				Autocomplete: argument.Autocomplete != nil,
+			}
+
+			switch argument.Type {
+			case ArgumentString, ArgumentText:
+				option.Type = discordgo.ApplicationCommandOptionString
+			case ArgumentInteger:
+				option.Type = discordgo.ApplicationCommandOptionInteger
+			case ArgumentUser:
+				option.Type = discordgo.ApplicationCommandOptionUser
+			case ArgumentChannel:
+				option.Type = discordgo.ApplicationCommandOptionChannel
+			}
+
+			for _, choice := range argument.Choices {
+				option.Choices = append(option.Choices, &discordgo.ApplicationCommandOptionChoice{Name: choice, Value: choice})
+			}
+
+			appCommand.Options = append(appCommand.Options, option)
+		}
+
+		appCommands = append(appCommands, appCommand)
+	}
+
+	return appCommands, nil
+}
+
+// SyncApplicationCommands replaces the slash commands in every server the bot is configured for with the provided
+// commands.
+func SyncApplicationCommands(ctx context.Context, utils *lib.Utils, commands []Command) error {
+	appCommands, err := ApplicationCommands(commands)
+	if err != nil {
+		return err
+	}
+
+	for _, guildID := range utils.Config().GuildIDs() {
+		_, err := utils.Discord.ApplicationCommandBulkOverwrite(utils.Discord.BotUser().ID, guildID, appCommands, discordgo.WithContext(ctx))
+		if err != nil {
+			return fmt.Errorf("server %s: %w", guildID, err)
+		}
+	}
+
+	return nil
+}
+
+// slashArguments returns all the arguments the command's slash command takes.
+func slashArguments(command Command) []Argument {
+	if command.RequiresUser() {
+		return append([]Argument{userArgument}, command.Arguments()...)
+	}
+
+	return command.Arguments()
+}
+
+// handleInteraction is called on every interaction and runs slash commands.
+func (c *Commands) handleInteraction(_ *discordgo.Session, interactionCreate *discordgo.InteractionCreate) {
+	switch interactionCreate.Type {
+	case discordgo.InteractionApplicationCommand:
+		c.handleSlashCommand(interactionCreate.Interaction)
+	case discordgo.InteractionApplicationCommandAutocomplete:
+		c.handleAutocomplete(interactionCreate.Interaction)
+	}
+}
+
+// handleSlashCommand runs a slash command the same way as if it were sent as a message.
+func (c *Commands) handleSlashCommand(interaction *discordgo.Interaction) {
+	// Slash commands can't be sent in DMs, so this should always be set
+	if interaction.Member == nil {
+		return
+	}
+
+	// Add uuid to logs about this command execution for easier debugging
+	uuid := uuid2.New().String()
+	utils := c.Utils.NewWithLog(lib.AddString("uuid", uuid))
+
+	data := interaction.ApplicationCommandData()
+
+	source := &interactionSource{
+		interaction: interaction,
+		command:     c.commands[data.Name],
+		Utils:       utils,
+	}
+
+	// The acknowledgement is part of the work being waited on when shutting down, as well as the command itself
+	_, done := utils.Begin(commandTimeout)
+	defer done()
+
+	// Acknowledge the interaction right away - discord requires a response within 3 seconds
+	err := c.Discord.InteractionRespond(interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseDeferredChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Flags: source.flags()},
+	})
+	if err != nil {
+		utils.Log.Error().Err(err).Msg("failed to acknowledge interaction")
+		return
+	}
+
+	message := &discordgo.Message{
+		ID:        interaction.ID,
+		ChannelID: interaction.ChannelID,
+		GuildID:   interaction.GuildID,
+		Author:    interaction.Member.User,
+		Member:    interaction.Member,
+		Content:   c.Config().Prefix + source.text(),
+		Timestamp: time.Now(),
+	}
+
+	invoker := commandInvoker{
+		uuid:     uuid,
+		message:  message,
+		source:   source,
+		commands: c.commands,
+		Utils:    utils.WithReplier(source),
+	}
+
+	invoker.invoke()
+}
+
+// handleAutocomplete suggests argument values as a user types them.
+func (c *Commands) handleAutocomplete(interaction *discordgo.Interaction) {
+	if interaction.Member == nil {
+		return
+	}
+
+	config := c.Config()
+	guild := config.Guild(interaction.GuildID)
+	if guild == nil {
+		return
+	}
+
+	member := interaction.Member
+	member.GuildID = interaction.GuildID
+	if !isStaff(config, guild, member) {
+		return
+	}
+
+	data := interaction.ApplicationCommandData()
+
+	command, ok := c.commands[data.Name]
+	if !ok {
+		return
+	}
+
+	var choices []*discordgo.ApplicationCommandOptionChoice
+	for _, option := range data.Options {
+		if !option.Focused {
+			continue
+		}
+
+		for _, argument := range slashArguments(command) {
+			if argument.Name != option.Name || argument.Autocomplete == nil {
+				continue
+			}
+
+			for _, suggestion := range argument.Autocomplete(c.Utils, fmt.Sprint(option.Value)) {
+				// discord allows at most 25 choices
+				if len(choices) == 25 {
+					break
+				}
+
+				choices = append(choices, &discordgo.ApplicationCommandOptionChoice{Name: suggestion, Value: suggestion})
+			}
+		}
+	}
+
+	err := c.Discord.InteractionRespond(interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionApplicationCommandAutocompleteResult,
+		Data: &discordgo.InteractionResponseData{Choices: choices},
+	})
+	if err != nil {
+		c.Log.Error().Err(err).Msg("failed to send autocomplete choices")
+	}
+}
+
+// interactionSource is a command sent as a slash command.
+type interactionSource struct {
+	// the slash command interaction
+	interaction *discordgo.Interaction
+
+	// the command being run, nil if it doesn't exist
+	command Command
+
+	// guards replied
+	lock sync.Mutex
+
+	// whether the deferred response has been replaced with a reply yet
+	replied bool
+
+	// id of the message that replaced the deferred response
+	responseID string
+
+	// general utilities
+	*lib.Utils
+}
+
+// flags returns the message flags to use for replies
+func (i *interactionSource) flags() discordgo.MessageFlags {
+	if ephemeral, ok := i.command.(EphemeralCommand); ok && ephemeral.Ephemeral() {
+		return discordgo.MessageFlagsEphemeral
+	}
+
+	return 0
+}
+
+// text returns the command as if it were typed as a message, without the prefix
+func (i *interactionSource) text() string {
+	details := i.details()
+	if details.text == "" {
+		return details.Name
+	}
+
+	return details.Name + " " + details.text
+}
+
+// parse converts the slash command's options into a command
+func (i *interactionSource) parse() (*CommandDetails, error) {
+	return i.details(), nil
+}
+
+// details converts the slash command's options into a command. Args are in the order the command declares them,
+// and the raw text is the args separated by spaces.
+func (i *interactionSource) details() *CommandDetails {
+	data := i.interaction.ApplicationCommandData()
+
+	details := &CommandDetails{Name: data.Name}
+	if i.command == nil {
+		return details
+	}
+
+	var text strings.Builder
+	for _, argument := range slashArguments(i.command) {
+		for _, option := range data.Options {
+			if option.Name != argument.Name {
+				continue
+			}
+
+			var value string
+			switch option.Type {
+			case discordgo.ApplicationCommandOptionInteger:
+				value = strconv.FormatInt(option.IntValue(), 10)
+			default:
+				// strings, and ids for users and channels
+				value = fmt.Sprint(option.Value)
+			}
+
+			if text.Len() != 0 {
+				text.WriteString(" ")
+			}
+
+			start := text.Len()
+			text.WriteString(value)
+
+			details.Args = append(details.Args, value)
+			details.tokens = append(details.tokens, token{value: value, start: start})
+		}
+	}
+
+	details.text = text.String()
+	return details
+}
+
+// Reply sends a reply to the slash command. The first reply replaces the deferred response, later ones are followups.
+func (i *interactionSource) Reply(_ *discordgo.Message, message *discordgo.MessageSend) (*discordgo.Message, error) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if !i.replied {
+		i.replied = true
+
+		edit := &discordgo.WebhookEdit{Content: &message.Content, Files: message.Files}
+		if message.Components != nil {
+			edit.Components = &message.Components
+		}
+		if message.Embeds != nil {
+			edit.Embeds = &message.Embeds
+		}
+
+		response, err := i.Discord.InteractionResponseEdit(i.interaction, edit)
+		if err != nil {
+			return nil, err
+		}
+
+		i.responseID = response.ID
+		return response, nil
+	}
+
+	return i.Discord.FollowupMessageCreate(i.interaction, true, &discordgo.WebhookParams{
+		Content:    message.Content,
+		Components: message.Components,
+		Embeds:     message.Embeds,
+		Files:      message.Files,
+		Flags:      i.flags(),
+	})
+}
+
+// Edit edits a reply, which may be the original response or a followup.
+func (i *interactionSource) Edit(sent *discordgo.Message, edit *discordgo.MessageEdit) error {
+	webhookEdit := &discordgo.WebhookEdit{Content: edit.Content}
+	if edit.Components != nil {
+		webhookEdit.Components = &edit.Components
+	}
+	if edit.Embeds != nil {
+		webhookEdit.Embeds = &edit.Embeds
+	}
+
+	i.lock.Lock()
+	original := sent.ID == i.responseID
+	i.lock.Unlock()
+
+	var err error
+	if original {
+		_, err = i.Discord.InteractionResponseEdit(i.interaction, webhookEdit)
+	} else {
+		_, err = i.Discord.FollowupMessageEdit(i.interaction, sent.ID, webhookEdit)
+	}
+
+	return err
+}
+
+// reply sends a text reply to the slash command.
+func (i *interactionSource) reply(message string) error {
+	_, err := i.Reply(nil, &discordgo.MessageSend{Content: message})
+	return err
+}
+
+// ignored removes the deferred response and privately tells the user the command won't be run
+func (i *interactionSource) ignored() {
+	err := i.Discord.InteractionResponseDelete(i.interaction)
+	if err != nil {
+		i.Log.Error().Err(err).Msg("failed to delete interaction response")
+	}
+
+	_, err = i.Discord.FollowupMessageCreate(i.interaction, true, &discordgo.WebhookParams{
+		Content: "You can't use bot commands here.",
+		Flags:   discordgo.MessageFlagsEphemeral,
+	})
+	if err != nil {
+		i.Log.Error().Err(err).Msg("failed to send interaction followup")
+	}
+}
+
+// finished replaces the deferred response if the command didn't reply, so it doesn't look like it's still running
+func (i *interactionSource) finished(result database.CommandResult) {
+	i.lock.Lock()
+	replied := i.replied
+	i.lock.Unlock()
+
+	if !replied {
+		message := "Failed."
+		switch result {
+		case database.CommandSucceeded:
+			message = "Done."
+		case database.CommandRejected:
+			message = "Cancelled."
+		}
+
+		err := i.reply(message)
+		if err != nil {
+			i.Log.Error().Err(err).Msg("failed to send interaction response")
+		}
+	}
+}
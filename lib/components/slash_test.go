@@ -0,0 +1,212 @@
+package components
+
+import (
+	"context"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/fakediscord"
+	"github.com/rs/zerolog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCommand is a command with whatever name and arguments a test needs, which does nothing when handled.
+type testCommand struct {
+	name         string
+	requiresUser bool
+	arguments    []Argument
+}
+
+func (t *testCommand) Name() string           { return t.name }
+func (t *testCommand) RequiresUser() bool     { return t.requiresUser }
+func (t *testCommand) Permission() Permission { return PermissionAdmin }
+func (t *testCommand) Description() string    { return "test command " + t.name }
+func (t *testCommand) Arguments() []Argument  { return t.arguments }
+func (t *testCommand) Setup(*lib.Utils)       {}
+func (t *testCommand) Handle(context.Context, *CommandDetails, *discordgo.Message, *lib.Utils) error {
+	return nil
+}
+
+func TestApplicationCommands(t *testing.T) {
+	command := &testCommand{
+		name:         "warn",
+		requiresUser: true,
+		arguments: []Argument{
+			{Name: "count", Type: ArgumentInteger},
+			{Name: "kind", Type: ArgumentString, Choices: []string{"a", "b"}},
+			{Name: "reason", Type: ArgumentText, Required: true, Autocomplete: func(*lib.Utils, string) []string { return nil }},
+		},
+	}
+
+	appCommands, err := ApplicationCommands([]Command{command})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(appCommands) != 1 {
+		t.Fatalf("got %d commands, want 1", len(appCommands))
+	}
+
+	options := appCommands[0].Options
+	want := []struct {
+		name         string
+		optionType   discordgo.ApplicationCommandOptionType
+		choices      int
+		autocomplete bool
+	}{
+		{"user", discordgo.ApplicationCommandOptionUser, 0, false},
+		{"count", discordgo.ApplicationCommandOptionInteger, 0, false},
+		{"kind", discordgo.ApplicationCommandOptionString, 2, false},
+		{"reason", discordgo.ApplicationCommandOptionString, 0, true},
+	}
+
+	if len(options) != len(want) {
+		t.Fatalf("got %d options, want %d", len(options), len(want))
+	}
+
+	for i, option := range options {
+		if option.Name != want[i].name || option.Type != want[i].optionType || len(option.Choices) != want[i].choices || option.Autocomplete != want[i].autocomplete {
+			t.Errorf("option %d = %s %v %d choices autocomplete %v, want %+v", i, option.Name, option.Type, len(option.Choices), option.Autocomplete, want[i])
+		}
+	}
+}
+
+func TestApplicationCommandsChoicesAndAutocomplete(t *testing.T) {
+	command := &testCommand{
+		name: "bad",
+		arguments: []Argument{
+			{Name: "kind", Choices: []string{"a"}, Autocomplete: func(*lib.Utils, string) []string { return nil }},
+		},
+	}
+
+	_, err := ApplicationCommands([]Command{command})
+	if err == nil || !strings.Contains(err.Error(), "both choices and autocomplete") {
+		t.Fatalf("ApplicationCommands error = %v, want one about choices and autocomplete", err)
+	}
+
+	if _, err = NewCommands([]Command{command}); err == nil {
+		t.Fatal("NewCommands accepted a command discord would reject")
+	}
+}
+
+func TestInteractionSourceFinished(t *testing.T) {
+	tests := []struct {
+		result  database.CommandResult
+		replied bool
+		want    []string
+	}{
+		{database.CommandSucceeded, false, []string{"Done."}},
+		{database.CommandRejected, false, []string{"Cancelled."}},
+		{database.CommandFailed, false, []string{"Failed."}},
+		{database.CommandPanicked, false, []string{"Failed."}},
+		{database.CommandSucceeded, true, []string{"reply"}},
+		{database.CommandRejected, true, []string{"reply"}},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.result), func(t *testing.T) {
+			fake := fakediscord.New("bot")
+			guild := fake.AddGuild("guild")
+			channel := fake.AddChannel(guild.ID, "commands", discordgo.ChannelTypeGuildText, "")
+
+			source := &interactionSource{
+				interaction: &discordgo.Interaction{ID: fake.NewId(), ChannelID: channel.ID},
+				Utils:       &lib.Utils{Discord: fake},
+			}
+
+			if test.replied {
+				if err := source.reply("reply"); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			source.finished(test.result)
+
+			var got []string
+			for _, message := range fake.Messages(channel.ID) {
+				got = append(got, message.Content)
+			}
+
+			if strings.Join(got, "|") != strings.Join(test.want, "|") {
+				t.Errorf("messages = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// blockingCommand is a command which doesn't reply, and blocks until it's cancelled.
+type blockingCommand struct {
+	testCommand
+	started chan struct{}
+}
+
+func (b *blockingCommand) Handle(ctx context.Context, _ *CommandDetails, _ *discordgo.Message, _ *lib.Utils) error {
+	close(b.started)
+	<-ctx.Done()
+	return nil
+}
+
+// slowResponses is a fake discord which takes a while to edit interaction responses.
+type slowResponses struct {
+	*fakediscord.Discord
+}
+
+func (s slowResponses) InteractionResponseEdit(interaction *discordgo.Interaction, edit *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error) {
+	time.Sleep(50 * time.Millisecond)
+	return s.Discord.InteractionResponseEdit(interaction, edit, options...)
+}
+
+func TestSlashCommandRepliesBeforeShutdown(t *testing.T) {
+	fake := fakediscord.New("bot")
+	guild := fake.AddGuild("guild")
+	admin := fake.AddRole(guild.ID, "admin", discordgo.PermissionAdministrator)
+	category := fake.AddChannel(guild.ID, "staff", discordgo.ChannelTypeGuildCategory, "")
+	channel := fake.AddChannel(guild.ID, "commands", discordgo.ChannelTypeGuildText, category.ID)
+	user := fake.AddUser("staff")
+	fake.AddMember(guild.ID, user, admin.ID)
+
+	config := &lib.Config{
+		Prefix:  "$",
+		Servers: lib.ServerConfig{Home: guild.ID},
+		GuildConfig: lib.GuildConfig{
+			Categories: lib.CategoryConfig{CommandsEnabled: []string{category.ID}},
+			Roles:      lib.RoleConfig{Admin: []string{admin.ID}},
+		},
+	}
+
+	db, err := database.New(filepath.Join(t.TempDir(), "bouncer.db"), guild.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	command := &blockingCommand{testCommand: testCommand{name: "block"}, started: make(chan struct{})}
+	commands, err := NewCommands([]Command{command})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bot := lib.NewBot([]lib.Component{commands}, config, db, zerolog.Nop(), lib.NewLogBuffer(10))
+	if err = bot.Start(slowResponses{Discord: fake}); err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_, _ = fake.SlashCommand(user, channel.ID, "block")
+	}()
+	<-command.started
+
+	// shutting down cancels the command, and waits for its response to be sent
+	bot.Stop()
+
+	messages := fake.Messages(channel.ID)
+	if len(messages) != 1 || messages[0].Content != "Done." {
+		var contents []string
+		for _, message := range messages {
+			contents = append(contents, message.Content)
+		}
+		t.Errorf("messages = %q, want [Done.]", contents)
+	}
+}
@@ -0,0 +1,45 @@
+package components
+
+import (
+	"context"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/events"
+	"time"
+)
+
+// Stats is a component that keeps count of bans and warns per staff member and per month in each server, for $graph.
+type Stats struct {
+	*lib.Utils
+}
+
+// NewStats creates a stats component.
+func NewStats() *Stats {
+	return &Stats{}
+}
+
+// Setup subscribes to ban and warn events.
+func (s *Stats) Setup(utils *lib.Utils) error {
+	s.Utils = utils
+
+	lib.Subscribe(utils, s.userBanned)
+	lib.Subscribe(utils, s.userWarned)
+
+	return nil
+}
+
+func (s *Stats) userBanned(ctx context.Context, event events.UserBanned) {
+	s.increment(ctx, event.Guild, event.Staff.ID, event.Date, database.EntryBan)
+}
+
+func (s *Stats) userWarned(ctx context.Context, event events.UserWarned) {
+	s.increment(ctx, event.Guild, event.Staff.ID, event.Date, database.EntryWarn)
+}
+
+// increment counts the action in the staff member's and month's stats in the server.
+func (s *Stats) increment(ctx context.Context, guildID, staffID string, date time.Time, kind database.EntryKind) {
+	err := s.Repo().IncrementStaffStats(ctx, guildID, staffID, date, kind)
+	if err != nil {
+		s.Log.Error().Err(err).Str("guild", guildID).Str("staff", staffID).Str("kind", string(kind)).Msg("failed to update stats")
+	}
+}
@@ -0,0 +1,267 @@
+package components
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/danvolchek/bouncer-go/lib"
+	uuid2 "github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// sysLogField is the field the syslog marks its own logs with, so it doesn't forward them
+	sysLogField = "syslog"
+
+	// how often pending events are posted
+	sysLogFlushInterval = 30 * time.Second
+
+	// how long after an event is posted that repeats of it are held back instead of being posted again
+	sysLogDedupWindow = 10 * time.Minute
+
+	// max number of events in a single post, chosen so posts stay under discord's message length limit
+	sysLogMaxPerPost = 5
+
+	// max number of distinct events waiting to be posted - any more are dropped
+	sysLogMaxPending = 100
+
+	// max length of a single event's text, in characters
+	sysLogMaxLength = 300
+)
+
+// SysLog is a component that forwards warning and error log events to the syslog channel, so staff can see them
+// without access to the host. It's a zerolog writer - add it to the logger's outputs.
+// Events are batched and posted periodically, and repeated events are combined and held back for a while after
+// being posted, so a failure loop can't flood the channel.
+type SysLog struct {
+	// marks this syslog's own logs - see sysLogField
+	id string
+
+	// guards everything below
+	lock sync.Mutex
+
+	// events waiting to be posted, in the order they were first seen
+	pending []*sysLogEvent
+
+	// map from event key to pending event
+	pendingByKey map[string]*sysLogEvent
+
+	// map from event key to when it was last posted
+	lastPosted map[string]time.Time
+
+	// number of events dropped since the last post because too many were pending
+	dropped int
+
+	// closed to stop posting periodically, and closed once that's stopped
+	stop    chan struct{}
+	stopped chan struct{}
+
+	// general utilities
+	*lib.Utils
+}
+
+// sysLogEvent is a log event waiting to be posted.
+type sysLogEvent struct {
+	key   string
+	level zerolog.Level
+	text  string
+	uuid  string
+	count int
+}
+
+// NewSysLog creates a syslog forwarder.
+func NewSysLog() *SysLog {
+	return &SysLog{
+		id:           uuid2.New().String(),
+		pendingByKey: make(map[string]*sysLogEvent),
+		lastPosted:   make(map[string]time.Time),
+	}
+}
+
+// Setup is called before the bot is started.
+func (s *SysLog) Setup(utils *lib.Utils) error {
+	s.Utils = utils.NewWithLog(lib.AddString(sysLogField, s.id))
+
+	if s.Config().Channels.SysLog == "" {
+		s.Log.Info().Msg("no syslog channel configured - not forwarding log events")
+	}
+
+	return nil
+}
+
+// Start starts posting events to the syslog channel periodically. Nothing is posted while no syslog channel is
+// configured, but one can be added by reloading the config.
+func (s *SysLog) Start() error {
+	s.stop = make(chan struct{})
+	s.stopped = make(chan struct{})
+
+	go func() {
+		defer close(s.stopped)
+
+		ticker := time.NewTicker(sysLogFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.flush()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops posting events periodically, and posts any that are pending.
+func (s *SysLog) Stop() error {
+	if s.stop == nil {
+		return nil
+	}
+
+	close(s.stop)
+	<-s.stopped
+
+	s.flush()
+	return nil
+}
+
+// Write stores a JSON log event to be posted, if it's a warning or above.
+func (s *SysLog) Write(p []byte) (int, error) {
+	var fields struct {
+		Level string `json:"level"`
+	}
+	_ = json.Unmarshal(p, &fields)
+
+	level, err := zerolog.ParseLevel(fields.Level)
+	if err != nil {
+		return len(p), nil
+	}
+
+	return s.WriteLevel(level, p)
+}
+
+// WriteLevel stores a JSON log event to be posted, if it's a warning or above.
+func (s *SysLog) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level < zerolog.WarnLevel || level == zerolog.NoLevel {
+		return len(p), nil
+	}
+
+	var fields struct {
+		Message string `json:"message"`
+		Error   string `json:"error"`
+		Panic   any    `json:"panic"`
+		UUID    string `json:"uuid"`
+		Caller  string `json:"caller"`
+		SysLog  string `json:"syslog"`
+	}
+	if err := json.Unmarshal(p, &fields); err != nil || fields.SysLog == s.id {
+		return len(p), nil
+	}
+
+	text := fields.Message
+	if fields.Error != "" {
+		text += ": " + fields.Error
+	}
+	if fields.Panic != nil {
+		text += fmt.Sprintf(": %v", fields.Panic)
+	}
+	if fields.Caller != "" {
+		text += " (" + fields.Caller + ")"
+	}
+	if utf8.RuneCountInString(text) > sysLogMaxLength {
+		// cut on a character boundary so the text stays valid UTF-8
+		text = string([]rune(text)[:sysLogMaxLength-3]) + "..."
+	}
+
+	// repeats are identified by their level and text - not uuid, which is different every time
+	key := level.String() + text
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if event, ok := s.pendingByKey[key]; ok {
+		event.count++
+		event.uuid = fields.UUID
+		return len(p), nil
+	}
+
+	if len(s.pending) >= sysLogMaxPending {
+		s.dropped++
+		return len(p), nil
+	}
+
+	event := &sysLogEvent{key: key, level: level, text: text, uuid: fields.UUID, count: 1}
+	s.pending = append(s.pending, event)
+	s.pendingByKey[key] = event
+
+	return len(p), nil
+}
+
+// flush posts pending events to the syslog channel.
+func (s *SysLog) flush() {
+	channel := s.Config().Channels.SysLog
+	if channel == "" {
+		return
+	}
+
+	s.lock.Lock()
+
+	now := time.Now()
+
+	var toPost []*sysLogEvent
+	var remaining []*sysLogEvent
+	for _, event := range s.pending {
+		recentlyPosted := now.Sub(s.lastPosted[event.key]) < sysLogDedupWindow
+		if len(toPost) == sysLogMaxPerPost || recentlyPosted {
+			remaining = append(remaining, event)
+			continue
+		}
+
+		toPost = append(toPost, event)
+		s.lastPosted[event.key] = now
+		delete(s.pendingByKey, event.key)
+	}
+	s.pending = remaining
+
+	dropped := s.dropped
+	s.dropped = 0
+
+	// forget about events that haven't been seen in a while
+	for key, posted := range s.lastPosted {
+		if now.Sub(posted) >= sysLogDedupWindow {
+			delete(s.lastPosted, key)
+		}
+	}
+
+	s.lock.Unlock()
+
+	if len(toPost) == 0 && dropped == 0 {
+		return
+	}
+
+	var message strings.Builder
+	for _, event := range toPost {
+		message.WriteString(fmt.Sprintf("`%s` %s", strings.ToUpper(event.level.String()), event.text))
+		if event.count > 1 {
+			message.WriteString(fmt.Sprintf(" (x%d)", event.count))
+		}
+		if event.uuid != "" {
+			message.WriteString(fmt.Sprintf(" - `%slogs %s`", s.Config().Prefix, event.uuid))
+		}
+		message.WriteString("\n")
+	}
+
+	if dropped > 0 {
+		message.WriteString(fmt.Sprintf("%d more events were dropped.\n", dropped))
+	}
+
+	_, err := s.Discord.ChannelMessageSend(channel, message.String())
+	if err != nil {
+		s.Log.Error().Err(err).Msg("failed to post log events to syslog channel")
+	}
+}
@@ -0,0 +1,57 @@
+package components
+
+import (
+	"github.com/rs/zerolog"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSysLogTruncate(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    string
+	}{
+		{"short", "é", "é"},
+		{"exact", strings.Repeat("é", sysLogMaxLength), strings.Repeat("é", sysLogMaxLength)},
+		{"ascii", strings.Repeat("a", sysLogMaxLength+1), strings.Repeat("a", sysLogMaxLength-3) + "..."},
+		{"multibyte", strings.Repeat("é", sysLogMaxLength+1), strings.Repeat("é", sysLogMaxLength-3) + "..."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sysLog := NewSysLog()
+			log := zerolog.New(sysLog)
+			log.Warn().Msg(test.message)
+
+			if len(sysLog.pending) != 1 {
+				t.Fatalf("%d events pending, want 1", len(sysLog.pending))
+			}
+
+			text := sysLog.pending[0].text
+			if !utf8.ValidString(text) {
+				t.Errorf("text %q isn't valid UTF-8", text)
+			}
+			if text != test.want {
+				t.Errorf("text = %q, want %q", text, test.want)
+			}
+		})
+	}
+}
+
+func TestSysLogSkipsOwnLogs(t *testing.T) {
+	sysLog := NewSysLog()
+	log := zerolog.New(sysLog)
+
+	own := log.With().Str(sysLogField, sysLog.id).Logger()
+	own.Warn().Msg("failed to post")
+
+	// a component's name doesn't matter, only whether it's this syslog
+	other := log.With().Str("component", "syslog").Logger()
+	other.Warn().Msg("something else")
+
+	if len(sysLog.pending) != 1 || sysLog.pending[0].text != "something else" {
+		t.Errorf("pending = %v, want only the other component's event", sysLog.pending)
+	}
+}
@@ -0,0 +1,102 @@
+package components
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// token is a single argument parsed out of command text, along with where it was found in that text.
+type token struct {
+	// value is the argument with quotes and escapes resolved
+	value string
+
+	// start is the byte offset of the argument, as typed, in the text it was parsed from
+	start int
+}
+
+var errUnterminatedQuote = errors.New("a quote was opened but never closed")
+
+// tokenize splits text into arguments. The rules are:
+//   - arguments are separated by any amount of whitespace, including newlines
+//   - text in "double quotes" is part of a single argument, whitespace included
+//   - a backslash escapes a following quote, backslash, backtick or whitespace character; before anything else it's
+//     kept as is, so discord markdown escapes like \* survive
+//   - code blocks (`code` or ```code```) are kept verbatim, backticks included, as part of a single argument
+func tokenize(text string) ([]token, error) {
+	var tokens []token
+
+	var (
+		current  strings.Builder
+		inToken  bool
+		inQuotes bool
+		start    int
+	)
+
+	begin := func(i int) {
+		if !inToken {
+			inToken = true
+			start = i
+		}
+	}
+
+	for i := 0; i < len(text); {
+		r, size := utf8.DecodeRuneInString(text[i:])
+
+		switch {
+		case r == '\\':
+			begin(i)
+
+			next, nextSize := utf8.DecodeRuneInString(text[i+size:])
+			if nextSize > 0 && (next == '"' || next == '\\' || next == '`' || unicode.IsSpace(next)) {
+				current.WriteRune(next)
+				i += size + nextSize
+				continue
+			}
+
+			current.WriteRune(r)
+		case r == '`':
+			begin(i)
+
+			// a code block is a run of backticks closed by a run of the same length
+			fence := len(text[i:]) - len(strings.TrimLeft(text[i:], "`"))
+			end := strings.Index(text[i+fence:], text[i:i+fence])
+			if end == -1 {
+				// discord displays unclosed backticks literally, so do the same
+				current.WriteString(text[i : i+fence])
+				i += fence
+				continue
+			}
+
+			end += i + 2*fence
+			current.WriteString(text[i:end])
+			i = end
+			continue
+		case r == '"':
+			begin(i)
+			inQuotes = !inQuotes
+		case unicode.IsSpace(r) && !inQuotes:
+			if inToken {
+				tokens = append(tokens, token{value: current.String(), start: start})
+				current.Reset()
+				inToken = false
+			}
+		default:
+			begin(i)
+			current.WriteRune(r)
+		}
+
+		i += size
+	}
+
+	if inQuotes {
+		return nil, errUnterminatedQuote
+	}
+
+	if inToken {
+		tokens = append(tokens, token{value: current.String(), start: start})
+	}
+
+	return tokens, nil
+}
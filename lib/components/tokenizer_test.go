@@ -0,0 +1,108 @@
+package components
+
+import (
+	"errors"
+	"github.com/bwmarrin/discordgo"
+	"golang.org/x/exp/slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+		err  error
+	}{
+		{"empty", "", nil, nil},
+		{"whitespace only", "  \n\t ", nil, nil},
+		{"single", "ban", []string{"ban"}, nil},
+		{"whitespace separated", "a  b\nc\td", []string{"a", "b", "c", "d"}, nil},
+		{"quoted", `note "two words" after`, []string{"note", "two words", "after"}, nil},
+		{"quote inside word", `a"b c"d`, []string{"ab cd"}, nil},
+		{"empty quotes", `a "" b`, []string{"a", "", "b"}, nil},
+		{"escaped quote", `say \"hi\"`, []string{"say", `"hi"`}, nil},
+		{"escaped backslash", `a\\b`, []string{`a\b`}, nil},
+		{"escaped space", `a\ b c`, []string{"a b", "c"}, nil},
+		{"markdown escape kept", `\*bold\*`, []string{`\*bold\*`}, nil},
+		{"trailing backslash", `a\`, []string{`a\`}, nil},
+		{"inline code", "say `a b` c", []string{"say", "`a b`", "c"}, nil},
+		{"code block", "say ```go\nx := \"y\"\n``` done", []string{"say", "```go\nx := \"y\"\n```", "done"}, nil},
+		{"quotes in code ignored", "`\"` x", []string{"`\"`", "x"}, nil},
+		{"unclosed backtick", "a `b c", []string{"a", "`b", "c"}, nil},
+		{"unicode", "héllo wörld", []string{"héllo", "wörld"}, nil},
+		{"unterminated quote", `a "b c`, nil, errUnterminatedQuote},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tokens, err := tokenize(test.text)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("tokenize(%q) error = %v, want %v", test.text, err, test.err)
+			}
+
+			var got []string
+			for _, token := range tokens {
+				got = append(got, token.value)
+			}
+
+			if !slices.Equal(got, test.want) {
+				t.Errorf("tokenize(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestTokenizeOffsets(t *testing.T) {
+	text := `a  "b c"  d\ e`
+	tokens, err := tokenize(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int{0, 3, 10}
+	if len(tokens) != len(want) {
+		t.Fatalf("got %d tokens, want %d", len(tokens), len(want))
+	}
+
+	for i, token := range tokens {
+		if token.start != want[i] {
+			t.Errorf("token %d starts at %d, want %d", i, token.start, want[i])
+		}
+	}
+}
+
+func TestRaw(t *testing.T) {
+	source := messageSource{message: &discordgo.Message{Content: `$note  <@1>  "quoted"   reason with  spacing`}, prefix: "$"}
+
+	details, err := source.parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if details.Name != "note" {
+		t.Errorf("Name = %q, want note", details.Name)
+	}
+
+	tests := []struct {
+		from int
+		want string
+	}{
+		{0, `<@1>  "quoted"   reason with  spacing`},
+		{1, `"quoted"   reason with  spacing`},
+		{2, `reason with  spacing`},
+		{5, ``},
+		{-1, ``},
+	}
+
+	for _, test := range tests {
+		if got := details.RawFrom(test.from); got != test.want {
+			t.Errorf("RawFrom(%d) = %q, want %q", test.from, got, test.want)
+		}
+	}
+
+	details.shift()
+	if got, want := details.Raw(), `"quoted"   reason with  spacing`; got != want {
+		t.Errorf("Raw() after shift = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,268 @@
+package components
+
+import (
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"golang.org/x/exp/slices"
+	"math"
+	"strings"
+)
+
+// allPermissions is every permission, for owners and administrators. discordgo.PermissionAll is missing newer ones.
+const allPermissions int64 = math.MaxInt64
+
+// sendPermissions are the permissions the bot needs in every channel it posts in.
+const sendPermissions = discordgo.PermissionViewChannel | discordgo.PermissionSendMessages
+
+// moderationPermissions are the guild permissions the bot needs to moderate, and what doesn't work without each.
+var moderationPermissions = []struct {
+	permission int64
+	name       string
+	feature    string
+}{
+	{discordgo.PermissionBanMembers, "Ban Members", "banning and unbanning"},
+	{discordgo.PermissionKickMembers, "Kick Members", "kicking"},
+	{discordgo.PermissionModerateMembers, "Timeout Members", "timeouts"},
+	{discordgo.PermissionManageRoles, "Manage Roles", "adding and removing roles"},
+	{discordgo.PermissionManageMessages, "Manage Messages", "deleting messages"},
+}
+
+// configReport is a readable report of problems found in the config.
+type configReport struct {
+	// lines in the report
+	lines []string
+}
+
+// ok adds a line for something that's fine.
+func (r *configReport) ok(format string, args ...interface{}) {
+	r.lines = append(r.lines, "  ok   "+fmt.Sprintf(format, args...))
+}
+
+// warn adds a line for a problem that disables a feature.
+func (r *configReport) warn(format string, args ...interface{}) {
+	r.lines = append(r.lines, "  WARN "+fmt.Sprintf(format, args...))
+}
+
+// fail adds a line for a problem the bot can't run with.
+func (r *configReport) fail(format string, args ...interface{}) {
+	r.lines = append(r.lines, "  FAIL "+fmt.Sprintf(format, args...))
+}
+
+// hasProblems returns whether any line is a warning or failure.
+func (r *configReport) hasProblems() bool {
+	return slices.ContainsFunc(r.lines, func(line string) bool {
+		return !strings.HasPrefix(line, "  ok")
+	})
+}
+
+func (r *configReport) String() string {
+	return "Config check:\n" + strings.Join(r.lines, "\n")
+}
+
+// validateConfig checks that everything in the config exists in its server and that the bot has the permissions
+// each feature needs. Features with problems are disabled by removing them from the config, as are other servers the
+// bot isn't in. An error is returned if the bot can't run at all.
+func validateConfig(utils *lib.Utils, config *lib.Config) (*configReport, error) {
+	report := &configReport{}
+
+	err := validateGuild(utils, report, config.Servers.Home, &config.GuildConfig, config.Users)
+	if err != nil {
+		report.fail("home server %s: %s", config.Servers.Home, err)
+		return report, err
+	}
+
+	for _, id := range config.GuildIDs()[1:] {
+		err = validateGuild(utils, report, id, config.Guilds[id], config.Users)
+		if err != nil {
+			report.warn("server %s: %s - disabling it", id, err)
+			delete(config.Guilds, id)
+		}
+	}
+
+	return report, nil
+}
+
+// validateGuild checks one server's config, disabling features with problems. An error is returned if the bot can't
+// run in the server at all.
+func validateGuild(utils *lib.Utils, report *configReport, guildID string, config *lib.GuildConfig, users lib.UserConfig) error {
+	guild, err := utils.Discord.Guild(guildID)
+	if err != nil {
+		return fmt.Errorf("not found (%w)", err)
+	}
+
+	me, err := utils.Discord.GuildMember(guild.ID, utils.Discord.BotUser().ID)
+	if err != nil {
+		return fmt.Errorf("bot isn't a member (%w)", err)
+	}
+	report.ok("server %q", guild.Name)
+
+	roles, err := utils.Discord.GuildRoles(guild.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	channels, err := utils.Discord.GuildChannels(guild.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get channels: %w", err)
+	}
+
+	findChannel := func(id string) *discordgo.Channel {
+		i := slices.IndexFunc(channels, func(channel *discordgo.Channel) bool { return channel.ID == id })
+		if i == -1 {
+			return nil
+		}
+		return channels[i]
+	}
+
+	canSend := func(channel *discordgo.Channel) bool {
+		return memberPermissions(guild, roles, me, channel)&sendPermissions == sendPermissions
+	}
+
+	// categories
+	config.Categories.CommandsEnabled = removeIf(config.Categories.CommandsEnabled, func(id string) bool {
+		category := findChannel(id)
+		switch {
+		case category == nil || category.Type != discordgo.ChannelTypeGuildCategory:
+			report.warn("listening category %s: not found - commands there are disabled", id)
+			return true
+		case !canSend(category):
+			report.warn("listening category %q: bot can't view or send messages - commands there are disabled", category.Name)
+			return true
+		default:
+			report.ok("listening category %q", category.Name)
+			return false
+		}
+	})
+	if len(config.Categories.CommandsEnabled) == 0 {
+		report.warn("no usable listening categories - commands are disabled")
+	}
+
+	// channels
+	for _, configured := range []struct {
+		id      *string
+		name    string
+		feature string
+	}{
+		{&config.Channels.Mailbox, "mailbox", "DM forwarding"},
+		{&config.Channels.Spam, "spam", "spam reports"},
+		{&config.Channels.Log, "log", "the moderation log"},
+		{&config.Channels.SysLog, "syslog", "error reporting"},
+		{&config.Channels.Watchlist, "watchlist", "the watchlist"},
+		{&config.Channels.BanAppeal, "ban appeal", "ban appeals"},
+	} {
+		if *configured.id == "" {
+			continue
+		}
+
+		channel := findChannel(*configured.id)
+		switch {
+		case channel == nil:
+			report.warn("%s channel %s: not found - disabling %s", configured.name, *configured.id, configured.feature)
+			*configured.id = ""
+		case !canSend(channel):
+			report.warn("%s channel %q: bot can't view or send messages - disabling %s", configured.name, channel.Name, configured.feature)
+			*configured.id = ""
+		default:
+			report.ok("%s channel %q", configured.name, channel.Name)
+		}
+	}
+
+	config.Channels.SpamIgnored = removeIf(config.Channels.SpamIgnored, func(id string) bool {
+		if findChannel(id) == nil {
+			report.warn("spam ignored channel %s: not found - ignoring it", id)
+			return true
+		}
+		return false
+	})
+
+	// roles
+	checkRoles := func(name string, ids []string) []string {
+		return removeIf(ids, func(id string) bool {
+			i := slices.IndexFunc(roles, func(role *discordgo.Role) bool { return role.ID == id })
+			if i == -1 {
+				report.warn("%s role %s: not found - ignoring it", name, id)
+				return true
+			}
+
+			report.ok("%s role %q", name, roles[i].Name)
+			return false
+		})
+	}
+
+	config.Roles.Admin = checkRoles("admin", config.Roles.Admin)
+	config.Roles.Owner = checkRoles("owner", config.Roles.Owner)
+	config.Roles.DMThread = checkRoles("DM thread", config.Roles.DMThread)
+	for permission, ids := range config.Roles.Permissions {
+		config.Roles.Permissions[permission] = checkRoles(fmt.Sprintf("%q permission", permission), ids)
+	}
+
+	if len(config.Roles.Admin) == 0 && len(config.Roles.Owner) == 0 && len(users.Owners) == 0 {
+		report.warn("no admin roles, owner roles or owners - nobody can run admin commands")
+	}
+
+	// moderation permissions
+	permissions := memberPermissions(guild, roles, me, nil)
+	for _, needed := range moderationPermissions {
+		if permissions&needed.permission != needed.permission {
+			report.warn("bot doesn't have the %s permission - %s won't work", needed.name, needed.feature)
+		}
+	}
+
+	return nil
+}
+
+// memberPermissions returns the member's permissions in the channel, or in the guild if channel is nil.
+func memberPermissions(guild *discordgo.Guild, roles []*discordgo.Role, member *discordgo.Member, channel *discordgo.Channel) int64 {
+	if member.User.ID == guild.OwnerID {
+		return allPermissions
+	}
+
+	var permissions int64
+	for _, role := range roles {
+		if role.ID == guild.ID || slices.Contains(member.Roles, role.ID) {
+			permissions |= role.Permissions
+		}
+	}
+
+	if permissions&discordgo.PermissionAdministrator != 0 {
+		return allPermissions
+	}
+
+	if channel == nil {
+		return permissions
+	}
+
+	// apply overwrites: @everyone, then the member's roles, then the member
+	var roleAllow, roleDeny int64
+	for _, overwrite := range channel.PermissionOverwrites {
+		switch {
+		case overwrite.ID == guild.ID:
+			permissions = permissions&^overwrite.Deny | overwrite.Allow
+		case overwrite.Type == discordgo.PermissionOverwriteTypeRole && slices.Contains(member.Roles, overwrite.ID):
+			roleAllow |= overwrite.Allow
+			roleDeny |= overwrite.Deny
+		}
+	}
+	permissions = permissions&^roleDeny | roleAllow
+
+	for _, overwrite := range channel.PermissionOverwrites {
+		if overwrite.Type == discordgo.PermissionOverwriteTypeMember && overwrite.ID == member.User.ID {
+			permissions = permissions&^overwrite.Deny | overwrite.Allow
+		}
+	}
+
+	return permissions
+}
+
+// removeIf returns the ids without the ones f returns true for. f is called for every id, in order.
+func removeIf(ids []string, f func(id string) bool) []string {
+	var kept []string
+	for _, id := range ids {
+		if !f(id) {
+			kept = append(kept, id)
+		}
+	}
+
+	return kept
+}
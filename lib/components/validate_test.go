@@ -0,0 +1,174 @@
+package components
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/fakediscord"
+	"strings"
+	"testing"
+)
+
+func TestMemberPermissions(t *testing.T) {
+	const (
+		view = discordgo.PermissionViewChannel
+		send = discordgo.PermissionSendMessages
+		ban  = discordgo.PermissionBanMembers
+	)
+
+	guild := &discordgo.Guild{ID: "1", OwnerID: "10"}
+	roles := []*discordgo.Role{
+		{ID: "1", Permissions: view | send}, // @everyone
+		{ID: "2", Permissions: ban},
+		{ID: "3", Permissions: discordgo.PermissionAdministrator},
+	}
+	member := &discordgo.Member{User: &discordgo.User{ID: "20"}, Roles: []string{"2"}}
+
+	everyone := func(allow, deny int64) *discordgo.PermissionOverwrite {
+		return &discordgo.PermissionOverwrite{ID: "1", Type: discordgo.PermissionOverwriteTypeRole, Allow: allow, Deny: deny}
+	}
+	role := func(allow, deny int64) *discordgo.PermissionOverwrite {
+		return &discordgo.PermissionOverwrite{ID: "2", Type: discordgo.PermissionOverwriteTypeRole, Allow: allow, Deny: deny}
+	}
+	self := func(allow, deny int64) *discordgo.PermissionOverwrite {
+		return &discordgo.PermissionOverwrite{ID: "20", Type: discordgo.PermissionOverwriteTypeMember, Allow: allow, Deny: deny}
+	}
+
+	tests := []struct {
+		name       string
+		member     *discordgo.Member
+		overwrites []*discordgo.PermissionOverwrite
+		want       int64
+	}{
+		{"roles", member, nil, view | send | ban},
+		{"owner", &discordgo.Member{User: &discordgo.User{ID: "10"}}, []*discordgo.PermissionOverwrite{everyone(0, view)}, allPermissions},
+		{"administrator", &discordgo.Member{User: &discordgo.User{ID: "20"}, Roles: []string{"3"}}, []*discordgo.PermissionOverwrite{everyone(0, view)}, allPermissions},
+		{"everyone denied", member, []*discordgo.PermissionOverwrite{everyone(0, send)}, view | ban},
+		{"role allows over everyone", member, []*discordgo.PermissionOverwrite{role(send, 0), everyone(0, send)}, view | send | ban},
+		{"role denies", member, []*discordgo.PermissionOverwrite{role(0, view)}, send | ban},
+		{"role allow beats role deny", &discordgo.Member{User: &discordgo.User{ID: "20"}, Roles: []string{"2", "4"}}, []*discordgo.PermissionOverwrite{
+			role(send, 0),
+			{ID: "4", Type: discordgo.PermissionOverwriteTypeRole, Deny: send},
+		}, view | send | ban},
+		{"member allows over role", member, []*discordgo.PermissionOverwrite{self(view, 0), role(0, view)}, view | send | ban},
+		{"member denies over role", member, []*discordgo.PermissionOverwrite{role(send, 0), self(0, send)}, view | ban},
+		{"other roles and members ignored", member, []*discordgo.PermissionOverwrite{
+			{ID: "3", Type: discordgo.PermissionOverwriteTypeRole, Deny: view},
+			{ID: "30", Type: discordgo.PermissionOverwriteTypeMember, Deny: view},
+		}, view | send | ban},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			channel := &discordgo.Channel{ID: "5", PermissionOverwrites: test.overwrites}
+			if got := memberPermissions(guild, roles, test.member, channel); got != test.want {
+				t.Errorf("permissions = %b, want %b", got, test.want)
+			}
+		})
+	}
+
+	// overwrites only apply in the channel
+	if got := memberPermissions(guild, roles, member, nil); got != view|send|ban {
+		t.Errorf("guild permissions = %b, want %b", got, view|send|ban)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	fake := fakediscord.New("bot")
+	guild := fake.AddGuild("home")
+	category := fake.AddChannel(guild.ID, "staff", discordgo.ChannelTypeGuildCategory, "")
+	log := fake.AddChannel(guild.ID, "log", discordgo.ChannelTypeGuildText, category.ID)
+	hidden := fake.AddChannel(guild.ID, "hidden", discordgo.ChannelTypeGuildText, category.ID)
+	admin := fake.AddRole(guild.ID, "admin", 0)
+
+	// the bot can send everywhere but the hidden channel
+	guild.OwnerID = "1"
+	botRole := fake.AddRole(guild.ID, "bot", sendPermissions|discordgo.PermissionBanMembers)
+	me, err := fake.GuildMember(guild.ID, fake.BotUser().ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	me.Roles = []string{botRole.ID}
+	hidden.PermissionOverwrites = []*discordgo.PermissionOverwrite{
+		{ID: botRole.ID, Type: discordgo.PermissionOverwriteTypeRole, Deny: discordgo.PermissionViewChannel},
+	}
+
+	other := fake.AddGuild("other")
+
+	config := &lib.Config{
+		Servers: lib.ServerConfig{Home: guild.ID},
+		GuildConfig: lib.GuildConfig{
+			Categories: lib.CategoryConfig{CommandsEnabled: []string{category.ID, "404"}},
+			Channels: lib.ChannelConfig{
+				Log:         log.ID,
+				Spam:        "404",
+				Watchlist:   hidden.ID,
+				SpamIgnored: []string{log.ID, "404"},
+			},
+			Roles: lib.RoleConfig{Admin: []string{admin.ID, "404"}},
+		},
+		Guilds: map[string]*lib.GuildConfig{
+			other.ID: {},
+			"404":    {},
+		},
+	}
+
+	report, err := validateConfig(&lib.Utils{Discord: fake}, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// what's missing or unusable is disabled, and the rest is kept
+	home := config.GuildConfig
+	if len(home.Categories.CommandsEnabled) != 1 || home.Categories.CommandsEnabled[0] != category.ID {
+		t.Errorf("categories = %v, want only the one that exists", home.Categories.CommandsEnabled)
+	}
+	if home.Channels.Log != log.ID {
+		t.Errorf("log channel = %q, want it kept", home.Channels.Log)
+	}
+	if home.Channels.Spam != "" {
+		t.Errorf("spam channel = %q, want it disabled since it doesn't exist", home.Channels.Spam)
+	}
+	if home.Channels.Watchlist != "" {
+		t.Errorf("watchlist channel = %q, want it disabled since the bot can't see it", home.Channels.Watchlist)
+	}
+	if len(home.Channels.SpamIgnored) != 1 || home.Channels.SpamIgnored[0] != log.ID {
+		t.Errorf("spam ignored channels = %v, want only the one that exists", home.Channels.SpamIgnored)
+	}
+	if len(home.Roles.Admin) != 1 || home.Roles.Admin[0] != admin.ID {
+		t.Errorf("admin roles = %v, want only the one that exists", home.Roles.Admin)
+	}
+	if _, ok := config.Guilds[other.ID]; !ok {
+		t.Error("other server was disabled")
+	}
+	if _, ok := config.Guilds["404"]; ok {
+		t.Error("server the bot isn't in wasn't disabled")
+	}
+
+	for _, want := range []string{
+		`WARN spam channel 404: not found - disabling spam reports`,
+		`WARN watchlist channel "hidden": bot can't view or send messages - disabling the watchlist`,
+		`WARN admin role 404: not found - ignoring it`,
+		`WARN bot doesn't have the Kick Members permission - kicking won't work`,
+		`WARN server 404: not found`,
+		`ok   log channel "log"`,
+	} {
+		if !strings.Contains(report.String(), want) {
+			t.Errorf("report doesn't contain %q:\n%s", want, report)
+		}
+	}
+	if strings.Contains(report.String(), "Ban Members") {
+		t.Errorf("report says the bot can't ban:\n%s", report)
+	}
+}
+
+func TestValidateConfigMissingHome(t *testing.T) {
+	fake := fakediscord.New("bot")
+
+	report, err := validateConfig(&lib.Utils{Discord: fake}, &lib.Config{Servers: lib.ServerConfig{Home: "404"}})
+	if err == nil {
+		t.Fatal("validated a config whose home server doesn't exist")
+	}
+	if !strings.Contains(report.String(), "FAIL home server 404: not found") {
+		t.Errorf("report = %s, want the home server to fail", report)
+	}
+}
@@ -1,72 +1,119 @@
 package lib
 
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Config is the bot's config, loaded from config.json.
+// Any field can be overridden by an environment variable named after its path, e.g. BOUNCER_DISCORD or
+// BOUNCER_CHANNELS_LOG. Fields tagged secret have where they came from logged, but never their value.
+// Fields are validated according to their validate tag: required fields must be set, and snowflake fields (or every
+// element of them) must be discord ids.
 type Config struct {
-	// The bot token, used to log into discord.
-	Token string `json:"discord"`
+	// The bot token, used to log into discord. It's only needed to connect, so it isn't required when e.g. just
+	// checking the config.
+	// Taken from, in order: the BOUNCER_DISCORD environment variable, the token file, then this field.
+	Token string `json:"discord" secret:"true"`
+
+	// Path of a file containing the bot token, so it doesn't need to be in the config file. Relative paths are relative
+	// to the config file's directory.
+	TokenFile string `json:"discord_file"`
 
 	// The command prefix used for commands.
-	Prefix string `json:"command_prefix"`
+	Prefix string `json:"command_prefix" validate:"required"`
 
 	Servers ServerConfig `json:"servers"`
 
+	// The home server's config.
+	GuildConfig
+
+	Users UserConfig `json:"users"`
+
+	// Whether to only observe: nothing is changed in discord, and a copy of the database is used. What would have been
+	// done is logged instead. Useful for running next to another bot without both acting.
+	DryRun bool `json:"dry_run"`
+
+	// Config for the other servers the bot is for, by server id. Each has the same settings as the home server.
+	Guilds map[string]*GuildConfig `json:"guilds" validate:"snowflake"`
+}
+
+type ServerConfig struct {
+	// Server the bot is mainly for. It's configured at the top level, and is where errors are reported and config
+	// changes are logged. Other servers are configured in guilds.
+	Home string `json:"home" validate:"required,snowflake"`
+}
+
+// GuildConfig is the config for one server.
+type GuildConfig struct {
 	Categories CategoryConfig `json:"categories"`
 
 	Channels ChannelConfig `json:"channels"`
 
 	Roles RoleConfig `json:"roles"`
 
-	Users UserConfig `json:"users"`
-
 	DM DMConfig `json:"DM"`
-}
 
-type ServerConfig struct {
-	// Server the bot is for. The bot can only run for one server.
-	Home string `json:"home"`
+	// Whether bans are shared with the other servers that share bans: users banned here are banned there, and the
+	// other way round.
+	SharedBans bool `json:"shared_bans"`
 }
 
 type CategoryConfig struct {
 	// Categories where commands are enabled in. Commands sent in other categories are ignored.
-	CommandsEnabled []string `json:"listening"`
+	CommandsEnabled []string `json:"listening" validate:"snowflake"`
 }
 
 type ChannelConfig struct {
 	// Channel to log user DMs to.
-	Mailbox string `json:"mailbox"`
+	Mailbox string `json:"mailbox" validate:"snowflake"`
 
 	// Channel to log spam notifications to.
-	Spam string `json:"spam"`
+	Spam string `json:"spam" validate:"snowflake"`
 
 	// Channels where spam is ignored/allowed.
-	SpamIgnored []string `json:"ignore_spam"`
+	SpamIgnored []string `json:"ignore_spam" validate:"snowflake"`
 
 	// Channel to log general notifications to.
-	Log string `json:"log"`
+	Log string `json:"log" validate:"snowflake"`
 
 	// Channel to log system notifications (?) to.
-	SysLog string `json:"syslog"`
+	SysLog string `json:"syslog" validate:"snowflake"`
 
 	// Channel to log notifications about watched users to.
-	Watchlist string `json:"watchlist"`
+	Watchlist string `json:"watchlist" validate:"snowflake"`
 
 	// Channel to log ban appeal notifications to.
-	BanAppeal string `json:"ban_appeal"`
+	BanAppeal string `json:"ban_appeal" validate:"snowflake"`
 }
 
 type RoleConfig struct {
 	// Roles for admin users. These users are allowed to send commands, and are treated specially in other areas (e.g. are never marked as spammers).
-	Admin []string `json:"admin"`
+	Admin []string `json:"admin" validate:"snowflake"`
 
 	// Roles for bot owners. These users are also allowed to use the bot in certain ways.
-	Owner []string `json:"owner"`
+	Owner []string `json:"owner" validate:"snowflake"`
 
 	// Roles to add to DM threads.
-	DMThread []string `json:"dm_threads"`
+	DMThread []string `json:"dm_threads" validate:"snowflake"`
+
+	// Named sets of roles that commands can require to be run, in addition to the built-in admin and owner levels.
+	Permissions map[string][]string `json:"permissions" validate:"snowflake"`
 }
 
 type UserConfig struct {
 	// Bot owners, able to use the bot/change certain settings.
-	Owners []string `json:"owners"`
+	Owners []string `json:"owners" validate:"snowflake"`
 }
 
 type DMConfig struct {
@@ -76,3 +123,367 @@ type DMConfig struct {
 	// Whether to send users a message when they're warned indicating why.
 	SendWarnMessage bool `json:"warn"`
 }
+
+// deprecatedConfigKeys are top level keys from bouncer's config which aren't used, and why.
+var deprecatedConfigKeys = []struct {
+	key, reason string
+}{
+	{"debug", "use the -debug flag instead"},
+	{"messageForwarding", "its rolesToAddToThreads field is now roles.dm_threads"},
+}
+
+// envPrefix is the prefix of environment variables overriding config fields.
+const envPrefix = "BOUNCER_"
+
+// LoadConfig reads the config file at the path, applies environment variable overrides and the token file, and
+// validates it. Unknown keys, missing required fields and malformed ids are errors. Deprecated keys are ignored with a
+// warning. Where overridden fields and secrets came from is logged.
+func LoadConfig(path string, log zerolog.Logger) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config, warnings, err := decodeConfig(data)
+	for _, warning := range warnings {
+		log.Warn().Msg(warning)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sources, err := config.applyEnv(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	if config.TokenFile != "" && !strings.HasPrefix(sources["discord"], "environment") {
+		tokenFile := config.TokenFile
+		if !filepath.IsAbs(tokenFile) {
+			tokenFile = filepath.Join(filepath.Dir(path), tokenFile)
+		}
+
+		token, err := os.ReadFile(tokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read token file: %w", err)
+		}
+
+		config.Token = strings.TrimSpace(string(token))
+		sources["discord"] = "token file " + tokenFile
+	}
+
+	configFields(reflect.ValueOf(config).Elem(), "", func(field reflect.StructField, value reflect.Value, path string) {
+		source, ok := sources[path]
+		switch {
+		case ok:
+			log.Info().Msgf("Config %s taken from %s", path, source)
+		case field.Tag.Get("secret") == "true" && !value.IsZero():
+			log.Info().Msgf("Config %s taken from the config file", path)
+		}
+	})
+
+	err = config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+// decodeConfig parses config file contents, without validating them. Deprecated keys are removed and returned as
+// warnings.
+func decodeConfig(data []byte) (*Config, []string, error) {
+	var keys map[string]json.RawMessage
+	err := json.Unmarshal(data, &keys)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	var warnings []string
+	for _, deprecated := range deprecatedConfigKeys {
+		if _, ok := keys[deprecated.key]; ok {
+			warnings = append(warnings, fmt.Sprintf("%s is deprecated and ignored: %s", deprecated.key, deprecated.reason))
+			delete(keys, deprecated.key)
+		}
+	}
+
+	data, err = json.Marshal(keys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var config Config
+	err = decoder.Decode(&config)
+	if err != nil {
+		return nil, warnings, fmt.Errorf("failed to parse config file: %w", err)
+	}
+
+	return &config, warnings, nil
+}
+
+// applyEnv overrides fields with the environment variables named after them, returning a map from the path of each
+// overridden field to where it came from. Lists are comma separated, and maps are JSON objects.
+func (c *Config) applyEnv(lookup func(key string) (string, bool)) (map[string]string, error) {
+	sources := make(map[string]string)
+	var errs []error
+
+	configFields(reflect.ValueOf(c).Elem(), "", func(_ reflect.StructField, value reflect.Value, path string) {
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+		env, ok := lookup(name)
+		if !ok {
+			return
+		}
+
+		if err := setField(value, env); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			return
+		}
+
+		sources[path] = "environment variable " + name
+	})
+
+	return sources, errors.Join(errs...)
+}
+
+// ConfigPaths returns the path of every config field, in order.
+func ConfigPaths() []string {
+	var paths []string
+	configFields(reflect.ValueOf(&Config{}).Elem(), "", func(_ reflect.StructField, _ reflect.Value, path string) {
+		paths = append(paths, path)
+	})
+
+	return paths
+}
+
+// IsSecretConfig returns whether the config field at the path is secret.
+func IsSecretConfig(path string) bool {
+	field, _, ok := (&Config{}).field(path)
+	return ok && field.Tag.Get("secret") == "true"
+}
+
+// Get returns the value of the config field at the path, formatted the way Set takes it. Secret fields can't be read.
+func (c *Config) Get(path string) (string, error) {
+	field, value, ok := c.field(path)
+	if !ok {
+		return "", fmt.Errorf("there's no config field %s", path)
+	}
+
+	if field.Tag.Get("secret") == "true" {
+		return "", fmt.Errorf("%s is secret", path)
+	}
+
+	return formatField(value), nil
+}
+
+// Set sets the config field at the path from text: lists are comma separated, and maps are JSON objects. The config
+// isn't validated.
+func (c *Config) Set(path, text string) error {
+	_, value, ok := c.field(path)
+	if !ok {
+		return fmt.Errorf("there's no config field %s", path)
+	}
+
+	return setField(value, text)
+}
+
+// field returns the config field at the path, and whether there is one.
+func (c *Config) field(path string) (reflect.StructField, reflect.Value, bool) {
+	var found reflect.StructField
+	var foundValue reflect.Value
+	var ok bool
+
+	configFields(reflect.ValueOf(c).Elem(), "", func(field reflect.StructField, value reflect.Value, fieldPath string) {
+		if fieldPath == path {
+			found, foundValue, ok = field, value, true
+		}
+	})
+
+	return found, foundValue, ok
+}
+
+// setField sets a config field from text, e.g. an environment variable's value. See Config.Set.
+func setField(value reflect.Value, text string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(text)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return fmt.Errorf("%q isn't a boolean", text)
+		}
+		value.SetBool(b)
+	case reflect.Slice:
+		var items []string
+		for _, item := range strings.Split(text, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		value.Set(reflect.ValueOf(items))
+	case reflect.Map:
+		m := reflect.New(value.Type())
+		if err := json.Unmarshal([]byte(text), m.Interface()); err != nil {
+			return fmt.Errorf("isn't a JSON object: %w", err)
+		}
+		value.Set(m.Elem())
+	default:
+		return fmt.Errorf("can't set a %s from text", value.Kind())
+	}
+
+	return nil
+}
+
+// formatField formats a config field the way setField takes it.
+func formatField(value reflect.Value) string {
+	switch value.Kind() {
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	case reflect.Slice:
+		return strings.Join(value.Interface().([]string), ",")
+	case reflect.Map:
+		if value.Len() == 0 {
+			return ""
+		}
+
+		data, err := json.Marshal(value.Interface())
+		if err != nil {
+			panic(fmt.Sprintf("config field can't be marshalled: %s", err))
+		}
+		return string(data)
+	default:
+		return value.String()
+	}
+}
+
+// Guild returns the config for the server, or nil if the bot isn't configured for it.
+func (c *Config) Guild(guildID string) *GuildConfig {
+	if guildID == c.Servers.Home {
+		return &c.GuildConfig
+	}
+
+	return c.Guilds[guildID]
+}
+
+// GuildIDs returns the ids of every server the bot is configured for, home first and the rest in order.
+func (c *Config) GuildIDs() []string {
+	others := maps.Keys(c.Guilds)
+	slices.Sort(others)
+
+	return append([]string{c.Servers.Home}, others...)
+}
+
+// Copy returns a deep copy of the config.
+func (c *Config) Copy() *Config {
+	data, err := json.Marshal(c)
+	if err != nil {
+		panic(fmt.Sprintf("config can't be marshalled: %s", err))
+	}
+
+	var config Config
+	if err = json.Unmarshal(data, &config); err != nil {
+		panic(fmt.Sprintf("config can't be unmarshalled: %s", err))
+	}
+
+	return &config
+}
+
+// Validate checks that required fields are set and ids are snowflakes, returning every problem found.
+func (c *Config) Validate() error {
+	return errors.Join(validateConfigValue(reflect.ValueOf(c).Elem(), "")...)
+}
+
+// validateConfigValue validates the fields of a config struct. path is the struct's path in the config file.
+func validateConfigValue(value reflect.Value, path string) []error {
+	var errs []error
+
+	configFields(value, path, func(field reflect.StructField, fieldValue reflect.Value, fieldPath string) {
+		rules := strings.Split(field.Tag.Get("validate"), ",")
+		for _, rule := range rules {
+			switch rule {
+			case "required":
+				if fieldValue.IsZero() {
+					errs = append(errs, fmt.Errorf("%s is required", fieldPath))
+				}
+			case "snowflake":
+				errs = append(errs, validateSnowflakes(fieldValue, fieldPath)...)
+			}
+		}
+
+		if fieldValue.Type() == reflect.TypeOf(map[string]*GuildConfig{}) {
+			errs = append(errs, validateGuilds(value, fieldValue, fieldPath)...)
+		}
+	})
+
+	return errs
+}
+
+// validateGuilds validates the config of the other servers. config is the top level config.
+func validateGuilds(config reflect.Value, guilds reflect.Value, path string) []error {
+	var errs []error
+
+	home := config.FieldByName("Servers").FieldByName("Home").String()
+
+	iter := guilds.MapRange()
+	for iter.Next() {
+		id := iter.Key().String()
+		guildPath := path + "." + id
+
+		switch {
+		case !snowflakeRegexp.MatchString(id):
+			errs = append(errs, fmt.Errorf("%s: %q isn't a discord id", path, id))
+		case id == home:
+			errs = append(errs, fmt.Errorf("%s: the home server is configured at the top level", guildPath))
+		case iter.Value().IsNil():
+			errs = append(errs, fmt.Errorf("%s is empty", guildPath))
+		default:
+			errs = append(errs, validateConfigValue(iter.Value().Elem(), guildPath)...)
+		}
+	}
+
+	return errs
+}
+
+// configFields calls f with every field of a config struct that isn't itself a struct, and its path in the config
+// file. path is the struct's path.
+func configFields(value reflect.Value, path string, f func(field reflect.StructField, value reflect.Value, path string)) {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		fieldValue := value.Field(i)
+		fieldPath := strings.TrimPrefix(path+"."+strings.Split(field.Tag.Get("json"), ",")[0], ".")
+
+		if fieldValue.Kind() == reflect.Struct {
+			configFields(fieldValue, fieldPath, f)
+			continue
+		}
+
+		f(field, fieldValue, fieldPath)
+	}
+}
+
+// validateSnowflakes checks that a string, or the strings in a slice or map of them, are snowflakes.
+func validateSnowflakes(value reflect.Value, path string) []error {
+	switch value.Kind() {
+	case reflect.String:
+		if value.String() != "" && !snowflakeRegexp.MatchString(value.String()) {
+			return []error{fmt.Errorf("%s: %q isn't a discord id", path, value.String())}
+		}
+	case reflect.Slice:
+		var errs []error
+		for i := 0; i < value.Len(); i++ {
+			errs = append(errs, validateSnowflakes(value.Index(i), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errs
+	case reflect.Map:
+		var errs []error
+		iter := value.MapRange()
+		for iter.Next() {
+			errs = append(errs, validateSnowflakes(iter.Value(), path+"."+iter.Key().String())...)
+		}
+		return errs
+	}
+
+	return nil
+}
@@ -0,0 +1,218 @@
+package lib
+
+import (
+	"bytes"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfigWithoutToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	err := os.WriteFile(path, []byte(`{"command_prefix": "$", "servers": {"home": "100"}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("config without a token is invalid: %s", err)
+	}
+
+	if config.Token != "" {
+		t.Errorf("token = %q, want none", config.Token)
+	}
+}
+
+func TestRunWithoutToken(t *testing.T) {
+	bot := NewBot(nil, &Config{Prefix: "$", Servers: ServerConfig{Home: "100"}}, nil, zerolog.Nop(), NewLogBuffer(10))
+
+	err := bot.Run("")
+	if err == nil || !strings.Contains(err.Error(), "no bot token") {
+		t.Errorf("err = %v, want no bot token", err)
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		err     string
+		warning string
+	}{
+		{"valid", `{"command_prefix": "$", "servers": {"home": "100"}, "channels": {"log": "101"}}`, "", ""},
+		{"not json", `{"command_prefix": "$",`, "failed to parse config file", ""},
+		{"unknown key", `{"command_prefix": "$", "servers": {"home": "100"}, "prefix": "!"}`, `unknown field "prefix"`, ""},
+		{"unknown nested key", `{"command_prefix": "$", "servers": {"home": "100"}, "channels": {"logs": "101"}}`, `unknown field "logs"`, ""},
+		{"wrong type", `{"command_prefix": "$", "servers": {"home": 100}}`, "failed to parse config file", ""},
+		{"malformed id", `{"command_prefix": "$", "servers": {"home": "100"}, "channels": {"log": "#log"}}`, "channels.log", ""},
+		{"malformed id in list", `{"command_prefix": "$", "servers": {"home": "100"}, "roles": {"admin": ["101", "admins"]}}`, "roles.admin", ""},
+		{"malformed server id", `{"command_prefix": "$", "servers": {"home": "100"}, "guilds": {"other": {}}}`, "guilds", ""},
+		{"missing required field", `{"servers": {"home": "100"}}`, "command_prefix", ""},
+		{"missing home server", `{"command_prefix": "$"}`, "servers.home", ""},
+		{"deprecated key", `{"command_prefix": "$", "servers": {"home": "100"}, "debug": true}`, "", "debug is deprecated and ignored"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(path, []byte(test.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			var logs bytes.Buffer
+			_, err := LoadConfig(path, zerolog.New(&logs))
+			switch {
+			case test.err == "" && err != nil:
+				t.Errorf("err = %v, want none", err)
+			case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+				t.Errorf("err = %v, want it to mention %s", err, test.err)
+			}
+
+			if test.warning != "" && !strings.Contains(logs.String(), test.warning) {
+				t.Errorf("logs = %s, want a warning that %s", logs.String(), test.warning)
+			}
+		})
+	}
+}
+
+func TestApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"BOUNCER_COMMAND_PREFIX":    "!",
+		"BOUNCER_CHANNELS_LOG":      "101",
+		"BOUNCER_ROLES_ADMIN":       "102, 103,",
+		"BOUNCER_DM_BAN":            "false",
+		"BOUNCER_SHARED_BANS":       "true",
+		"BOUNCER_ROLES_PERMISSIONS": `{"mute": ["104"]}`,
+	}
+	lookup := func(key string) (string, bool) {
+		value, ok := env[key]
+		return value, ok
+	}
+
+	config := &Config{Prefix: "$", GuildConfig: GuildConfig{DM: DMConfig{SendBanMessage: true}}, Users: UserConfig{Owners: []string{"105"}}}
+	sources, err := config.applyEnv(lookup)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if config.Prefix != "!" || config.Channels.Log != "101" {
+		t.Errorf("prefix, log = %q, %q, want the environment's", config.Prefix, config.Channels.Log)
+	}
+	if strings.Join(config.Roles.Admin, ",") != "102,103" {
+		t.Errorf("admin roles = %q, want [102 103]", config.Roles.Admin)
+	}
+	if config.DM.SendBanMessage || !config.SharedBans {
+		t.Errorf("DM.ban, shared_bans = %t, %t, want false, true", config.DM.SendBanMessage, config.SharedBans)
+	}
+	if ids := config.Roles.Permissions["mute"]; len(ids) != 1 || ids[0] != "104" {
+		t.Errorf("permissions = %v, want mute: [104]", config.Roles.Permissions)
+	}
+
+	// fields without a variable are left alone
+	if len(config.Users.Owners) != 1 || config.Users.Owners[0] != "105" {
+		t.Errorf("owners = %q, want the config's", config.Users.Owners)
+	}
+
+	if len(sources) != len(env) || sources["DM.ban"] != "environment variable BOUNCER_DM_BAN" {
+		t.Errorf("sources = %v, want one per variable", sources)
+	}
+
+	// invalid values are errors, naming the variable
+	env = map[string]string{"BOUNCER_SHARED_BANS": "yes please", "BOUNCER_GUILDS": "[]"}
+	_, err = (&Config{}).applyEnv(lookup)
+	if err == nil || !strings.Contains(err.Error(), "BOUNCER_SHARED_BANS") || !strings.Contains(err.Error(), "BOUNCER_GUILDS") {
+		t.Errorf("err = %v, want both variables to be invalid", err)
+	}
+}
+
+func TestLoadConfigTokenPrecedence(t *testing.T) {
+	tests := []struct {
+		name      string
+		env       bool
+		tokenFile bool
+		want      string
+		source    string
+	}{
+		{"config file", false, false, "config-token", "taken from the config file"},
+		{"token file over config file", false, true, "file-token", "taken from token file"},
+		{"environment over token file", true, true, "env-token", "taken from environment variable BOUNCER_DISCORD"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "token"), []byte("file-token\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			tokenFile := ""
+			if test.tokenFile {
+				tokenFile = "token"
+			}
+			if test.env {
+				t.Setenv("BOUNCER_DISCORD", "env-token")
+			}
+
+			path := filepath.Join(dir, "config.json")
+			config := `{"discord": "config-token", "discord_file": "` + tokenFile + `", "command_prefix": "$", "servers": {"home": "100"}}`
+			if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			var logs bytes.Buffer
+			loaded, err := LoadConfig(path, zerolog.New(&logs))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if loaded.Token != test.want {
+				t.Errorf("token = %q, want %q", loaded.Token, test.want)
+			}
+
+			// where it came from is logged, but not the token itself
+			if !strings.Contains(logs.String(), test.source) || strings.Contains(logs.String(), "-token") {
+				t.Errorf("logs = %s, want the token's source and not the token", logs.String())
+			}
+		})
+	}
+}
+
+func TestGuilds(t *testing.T) {
+	config := &Config{
+		Servers:     ServerConfig{Home: "200"},
+		GuildConfig: GuildConfig{Channels: ChannelConfig{Log: "201"}},
+		Guilds: map[string]*GuildConfig{
+			"300": {Channels: ChannelConfig{Log: "301"}},
+			"100": {},
+		},
+	}
+
+	// home first, then the rest in order, whatever order they're in the map
+	if ids := strings.Join(config.GuildIDs(), ","); ids != "200,100,300" {
+		t.Errorf("ids = %s, want 200,100,300", ids)
+	}
+
+	// the home server's config is the top level one, and other servers' don't take anything from it
+	if guild := config.Guild("200"); guild != &config.GuildConfig {
+		t.Errorf("home config = %+v, want the top level config", guild)
+	}
+	if guild := config.Guild("300"); guild == nil || guild.Channels.Log != "301" {
+		t.Errorf("other config = %+v, want its own", guild)
+	}
+	if guild := config.Guild("100"); guild == nil || guild.Channels.Log != "" {
+		t.Errorf("empty config = %+v, want no log channel", guild)
+	}
+	if guild := config.Guild("400"); guild != nil {
+		t.Errorf("unconfigured server's config = %+v, want none", guild)
+	}
+
+	// the home server can't be configured twice
+	config.Prefix = "$"
+	config.Guilds["200"] = &GuildConfig{}
+	if err := config.Validate(); err == nil || !strings.Contains(err.Error(), "the home server is configured at the top level") {
+		t.Errorf("err = %v, want the home server in guilds to be invalid", err)
+	}
+}
@@ -0,0 +1,110 @@
+package lib
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"time"
+)
+
+//go:generate mockgen -typed -destination mocks/mock_discord.go . DiscordClient
+
+// DiscordClient is the part of the discord API the bot uses. Methods have the same signatures as discordgo.Session's,
+// so see its docs for details.
+// NewDiscordClient creates one connected to discord, and the fakediscord package has an in-memory fake for tests.
+type DiscordClient interface {
+	// AddHandler registers an event handler - see discordgo.Session.AddHandler.
+	AddHandler(handler interface{}) func()
+
+	// AddHandlerOnce registers an event handler that's removed after it's called once.
+	AddHandlerOnce(handler interface{}) func()
+
+	// Open connects to discord.
+	Open() error
+
+	// Close disconnects from discord.
+	Close() error
+
+	// BotUser returns the bot's own user. Only available once connected.
+	BotUser() *discordgo.User
+
+	// Users, guilds and members. Lookups use cached state when possible.
+	User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error)
+	Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error)
+	GuildRoles(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Role, error)
+	GuildChannels(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Channel, error)
+	GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error)
+	GuildMemberRoleAdd(guildID, userID, roleID string, options ...discordgo.RequestOption) error
+	GuildMemberRoleRemove(guildID, userID, roleID string, options ...discordgo.RequestOption) error
+
+	// Moderation.
+	GuildBan(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.GuildBan, error)
+	GuildBanCreateWithReason(guildID, userID, reason string, days int, options ...discordgo.RequestOption) error
+	GuildBanDelete(guildID, userID string, options ...discordgo.RequestOption) error
+	GuildMemberDeleteWithReason(guildID, userID, reason string, options ...discordgo.RequestOption) error
+	GuildMemberTimeout(guildID string, userID string, until *time.Time, options ...discordgo.RequestOption) error
+
+	// Channels, threads and DMs.
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	UserChannelCreate(recipientID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ThreadStartComplex(channelID string, data *discordgo.ThreadStart, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ThreadMemberAdd(threadID, memberID string, options ...discordgo.RequestOption) error
+
+	// Messages.
+	ChannelMessages(channelID string, limit int, beforeID, afterID, aroundID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
+	ChannelMessageSend(channelID string, content string, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageEditComplex(m *discordgo.MessageEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	ChannelMessageDelete(channelID, messageID string, options ...discordgo.RequestOption) error
+
+	// Slash commands and interactions.
+	ApplicationCommandBulkOverwrite(appID string, guildID string, commands []*discordgo.ApplicationCommand, options ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error)
+	InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, options ...discordgo.RequestOption) error
+	InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	InteractionResponseDelete(interaction *discordgo.Interaction, options ...discordgo.RequestOption) error
+	FollowupMessageCreate(interaction *discordgo.Interaction, wait bool, data *discordgo.WebhookParams, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	FollowupMessageEdit(interaction *discordgo.Interaction, messageID string, data *discordgo.WebhookEdit, options ...discordgo.RequestOption) (*discordgo.Message, error)
+}
+
+// session is a DiscordClient connected to discord.
+type session struct {
+	*discordgo.Session
+}
+
+// NewDiscordClient creates a client which connects to discord using the bot token.
+func NewDiscordClient(token string) (DiscordClient, error) {
+	discord, err := discordgo.New("Bot " + token)
+	if err != nil {
+		return nil, err
+	}
+
+	discord.Identify.Intents = discordgo.IntentsAll
+
+	return session{Session: discord}, nil
+}
+
+func (s session) BotUser() *discordgo.User {
+	return s.State.User
+}
+
+func (s session) Guild(guildID string, options ...discordgo.RequestOption) (*discordgo.Guild, error) {
+	if guild, err := s.State.Guild(guildID); err == nil {
+		return guild, nil
+	}
+
+	return s.Session.Guild(guildID, options...)
+}
+
+func (s session) GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error) {
+	if member, err := s.State.Member(guildID, userID); err == nil {
+		return member, nil
+	}
+
+	return s.Session.GuildMember(guildID, userID, options...)
+}
+
+func (s session) Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	if channel, err := s.State.Channel(channelID); err == nil {
+		return channel, nil
+	}
+
+	return s.Session.Channel(channelID, options...)
+}
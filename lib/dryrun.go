@@ -0,0 +1,149 @@
+package lib
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/rs/zerolog"
+	"time"
+)
+
+// dryRunId is the id of messages and threads that would have been created in dry run mode.
+const dryRunId = "0"
+
+// dryRun is a DiscordClient that only observes: reads go to discord, but anything that would change something - bans,
+// kicks, timeouts, role changes, deletions, messages, DMs and interaction responses - is logged as what would have been
+// done instead. Opening DM channels is let through, since it doesn't notify anyone.
+type dryRun struct {
+	DiscordClient
+
+	log zerolog.Logger
+}
+
+// NewDryRun wraps a client so that it doesn't change anything in discord, logging what would have been done instead.
+func NewDryRun(client DiscordClient, log zerolog.Logger) DiscordClient {
+	return dryRun{DiscordClient: client, log: log.With().Bool("dry_run", true).Logger()}
+}
+
+// message returns a stand-in for a message that would have been sent.
+func (d dryRun) message(channelID, content string) *discordgo.Message {
+	return &discordgo.Message{
+		ID:        dryRunId,
+		ChannelID: channelID,
+		Content:   content,
+		Author:    d.BotUser(),
+		Timestamp: time.Now(),
+	}
+}
+
+func (d dryRun) GuildMemberRoleAdd(guildID, userID, roleID string, _ ...discordgo.RequestOption) error {
+	d.log.Info().Str("guild", guildID).Str("user", userID).Str("role", roleID).Msg("would have added a role")
+	return nil
+}
+
+func (d dryRun) GuildMemberRoleRemove(guildID, userID, roleID string, _ ...discordgo.RequestOption) error {
+	d.log.Info().Str("guild", guildID).Str("user", userID).Str("role", roleID).Msg("would have removed a role")
+	return nil
+}
+
+func (d dryRun) GuildBanCreateWithReason(guildID, userID, reason string, days int, _ ...discordgo.RequestOption) error {
+	d.log.Info().Str("guild", guildID).Str("user", userID).Str("reason", reason).Int("days", days).Msg("would have banned a user")
+	return nil
+}
+
+func (d dryRun) GuildBanDelete(guildID, userID string, _ ...discordgo.RequestOption) error {
+	d.log.Info().Str("guild", guildID).Str("user", userID).Msg("would have unbanned a user")
+	return nil
+}
+
+func (d dryRun) GuildMemberDeleteWithReason(guildID, userID, reason string, _ ...discordgo.RequestOption) error {
+	d.log.Info().Str("guild", guildID).Str("user", userID).Str("reason", reason).Msg("would have kicked a user")
+	return nil
+}
+
+func (d dryRun) GuildMemberTimeout(guildID string, userID string, until *time.Time, _ ...discordgo.RequestOption) error {
+	event := d.log.Info().Str("guild", guildID).Str("user", userID)
+	if until != nil {
+		event = event.Time("until", *until)
+	}
+	event.Msg("would have timed out a user")
+	return nil
+}
+
+func (d dryRun) ThreadStartComplex(channelID string, data *discordgo.ThreadStart, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	d.log.Info().Str("channel", channelID).Str("name", data.Name).Msg("would have started a thread")
+	return &discordgo.Channel{ID: dryRunId, ParentID: channelID, Name: data.Name, Type: data.Type}, nil
+}
+
+func (d dryRun) ThreadMemberAdd(threadID, memberID string, _ ...discordgo.RequestOption) error {
+	d.log.Info().Str("thread", threadID).Str("user", memberID).Msg("would have added a user to a thread")
+	return nil
+}
+
+func (d dryRun) ChannelMessageSend(channelID string, content string, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	d.log.Info().Str("channel", channelID).Str("content", content).Msg("would have sent a message")
+	return d.message(channelID, content), nil
+}
+
+func (d dryRun) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	d.log.Info().Str("channel", channelID).Str("content", data.Content).Int("files", len(data.Files)).Msg("would have sent a message")
+	return d.message(channelID, data.Content), nil
+}
+
+func (d dryRun) ChannelMessageEditComplex(m *discordgo.MessageEdit, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	content := ""
+	if m.Content != nil {
+		content = *m.Content
+	}
+
+	d.log.Info().Str("channel", m.Channel).Str("message", m.ID).Str("content", content).Msg("would have edited a message")
+	return d.message(m.Channel, content), nil
+}
+
+func (d dryRun) ChannelMessageDelete(channelID, messageID string, _ ...discordgo.RequestOption) error {
+	d.log.Info().Str("channel", channelID).Str("message", messageID).Msg("would have deleted a message")
+	return nil
+}
+
+func (d dryRun) ApplicationCommandBulkOverwrite(_ string, guildID string, commands []*discordgo.ApplicationCommand, _ ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error) {
+	d.log.Info().Str("guild", guildID).Int("commands", len(commands)).Msg("would have updated slash commands")
+	return commands, nil
+}
+
+func (d dryRun) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, _ ...discordgo.RequestOption) error {
+	content := ""
+	if resp.Data != nil {
+		content = resp.Data.Content
+	}
+
+	d.log.Info().Str("channel", interaction.ChannelID).Str("content", content).Msg("would have responded to an interaction")
+	return nil
+}
+
+func (d dryRun) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	content := ""
+	if newresp.Content != nil {
+		content = *newresp.Content
+	}
+
+	d.log.Info().Str("channel", interaction.ChannelID).Str("content", content).Msg("would have edited an interaction response")
+	return d.message(interaction.ChannelID, content), nil
+}
+
+func (d dryRun) InteractionResponseDelete(interaction *discordgo.Interaction, _ ...discordgo.RequestOption) error {
+	d.log.Info().Str("channel", interaction.ChannelID).Msg("would have deleted an interaction response")
+	return nil
+}
+
+func (d dryRun) FollowupMessageCreate(interaction *discordgo.Interaction, _ bool, data *discordgo.WebhookParams, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	d.log.Info().Str("channel", interaction.ChannelID).Str("content", data.Content).Msg("would have sent a followup message")
+	return d.message(interaction.ChannelID, data.Content), nil
+}
+
+func (d dryRun) FollowupMessageEdit(interaction *discordgo.Interaction, messageID string, data *discordgo.WebhookEdit, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	content := ""
+	if data.Content != nil {
+		content = *data.Content
+	}
+
+	d.log.Info().Str("channel", interaction.ChannelID).Str("message", messageID).Str("content", content).Msg("would have edited a followup message")
+	return d.message(interaction.ChannelID, content), nil
+}
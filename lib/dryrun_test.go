@@ -0,0 +1,128 @@
+package lib_test
+
+import (
+	"bytes"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/fakediscord"
+	"github.com/rs/zerolog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDryRunChangesNothing(t *testing.T) {
+	fake := fakediscord.New("bot")
+	guild := fake.AddGuild("guild")
+	role := fake.AddRole(guild.ID, "muted", 0)
+	kept := fake.AddRole(guild.ID, "member", 0)
+	channel := fake.AddChannel(guild.ID, "general", discordgo.ChannelTypeGuildText, "")
+	user := fake.AddUser("user")
+	fake.AddMember(guild.ID, user, kept.ID)
+	banned := fake.AddUser("banned")
+	if err := fake.GuildBanCreateWithReason(guild.ID, banned.ID, "spam", 0); err != nil {
+		t.Fatal(err)
+	}
+	existing := fake.SendMessage(user, channel.ID, "hello")
+
+	var logs bytes.Buffer
+	dryRun := lib.NewDryRun(fake, zerolog.New(&logs))
+
+	content := "edited"
+	until := time.Now().Add(time.Hour)
+	interaction := &discordgo.Interaction{ID: "1", ChannelID: channel.ID}
+
+	check := func(what string, err error) {
+		t.Helper()
+		if err != nil {
+			t.Errorf("%s: %s", what, err)
+		}
+	}
+
+	check("adding a role", dryRun.GuildMemberRoleAdd(guild.ID, user.ID, role.ID))
+	check("removing a role", dryRun.GuildMemberRoleRemove(guild.ID, user.ID, kept.ID))
+	check("banning", dryRun.GuildBanCreateWithReason(guild.ID, user.ID, "spam", 0))
+	check("unbanning", dryRun.GuildBanDelete(guild.ID, banned.ID))
+	check("timing out", dryRun.GuildMemberTimeout(guild.ID, user.ID, &until))
+	check("kicking", dryRun.GuildMemberDeleteWithReason(guild.ID, user.ID, "spam"))
+	check("deleting a message", dryRun.ChannelMessageDelete(channel.ID, existing.ID))
+	check("adding to a thread", dryRun.ThreadMemberAdd(channel.ID, user.ID))
+	check("responding", dryRun.InteractionRespond(interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{Content: "hi"},
+	}))
+	check("deleting a response", dryRun.InteractionResponseDelete(interaction))
+
+	// calls that return something return stand-ins callers can use
+	messages := map[string]func() (*discordgo.Message, error){
+		"sending": func() (*discordgo.Message, error) {
+			return dryRun.ChannelMessageSend(channel.ID, "hi")
+		},
+		"sending complex": func() (*discordgo.Message, error) {
+			return dryRun.ChannelMessageSendComplex(channel.ID, &discordgo.MessageSend{Content: "hi"})
+		},
+		"editing": func() (*discordgo.Message, error) {
+			return dryRun.ChannelMessageEditComplex(&discordgo.MessageEdit{ID: existing.ID, Channel: channel.ID, Content: &content})
+		},
+		"editing a response": func() (*discordgo.Message, error) {
+			return dryRun.InteractionResponseEdit(interaction, &discordgo.WebhookEdit{Content: &content})
+		},
+		"following up": func() (*discordgo.Message, error) {
+			return dryRun.FollowupMessageCreate(interaction, true, &discordgo.WebhookParams{Content: "hi"})
+		},
+		"editing a followup": func() (*discordgo.Message, error) {
+			return dryRun.FollowupMessageEdit(interaction, "2", &discordgo.WebhookEdit{Content: &content})
+		},
+	}
+	for what, send := range messages {
+		message, err := send()
+		check(what, err)
+		if message == nil || message.ChannelID != channel.ID || message.Content == "" || message.Author == nil || message.Author.ID != fake.BotUser().ID {
+			t.Errorf("%s returned %+v, want a message in the channel from the bot", what, message)
+		}
+	}
+
+	thread, err := dryRun.ThreadStartComplex(channel.ID, &discordgo.ThreadStart{Name: "thread", Type: discordgo.ChannelTypeGuildPrivateThread})
+	check("starting a thread", err)
+	if thread == nil || thread.ParentID != channel.ID || thread.Name != "thread" {
+		t.Errorf("starting a thread returned %+v, want a thread in the channel", thread)
+	}
+
+	commands := []*discordgo.ApplicationCommand{{Name: "warn"}}
+	overwritten, err := dryRun.ApplicationCommandBulkOverwrite("", guild.ID, commands)
+	check("updating commands", err)
+	if len(overwritten) != 1 {
+		t.Errorf("updating commands returned %v, want the commands", overwritten)
+	}
+
+	// nothing reached discord
+	if len(fake.Sent()) != 0 {
+		t.Errorf("%d messages were sent", len(fake.Sent()))
+	}
+	if messages := fake.Messages(channel.ID); len(messages) != 1 || messages[0].Content != "hello" {
+		t.Errorf("channel has %d messages, want the existing one unchanged", len(messages))
+	}
+	if fake.Banned(guild.ID, user.ID) || !fake.Banned(guild.ID, banned.ID) {
+		t.Error("bans changed")
+	}
+	if !fake.TimedOutUntil(guild.ID, user.ID).IsZero() {
+		t.Error("user was timed out")
+	}
+	member, err := fake.GuildMember(guild.ID, user.ID)
+	if err != nil {
+		t.Fatalf("user was kicked: %s", err)
+	}
+	if len(member.Roles) != 1 || member.Roles[0] != kept.ID {
+		t.Errorf("roles = %v, want unchanged", member.Roles)
+	}
+	if len(fake.Commands(guild.ID)) != 0 {
+		t.Error("commands were updated")
+	}
+
+	// what would have been done is logged
+	for _, want := range []string{"would have banned a user", "would have sent a message", "would have responded to an interaction"} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs don't contain %q", want)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Package events contains the events published on the bot's event bus (see lib.Bus).
+package events
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"time"
+)
+
+// UserWarned is published when staff warn a user.
+type UserWarned struct {
+	// Guild is the id of the server it happened in.
+	Guild string
+
+	// User is the user who was warned.
+	User *discordgo.User
+
+	// Staff is the staff member who warned them.
+	Staff *discordgo.User
+
+	// Reason is why they were warned.
+	Reason string
+
+	// Count is how many times the user has now been warned, including this one.
+	Count int
+
+	// Date is when they were warned.
+	Date time.Time
+}
+
+// UserBanned is published when staff ban a user.
+type UserBanned struct {
+	// Guild is the id of the server it happened in.
+	Guild string
+
+	// User is the user who was banned.
+	User *discordgo.User
+
+	// Staff is the staff member who banned them.
+	Staff *discordgo.User
+
+	// Reason is why they were banned.
+	Reason string
+
+	// Date is when they were banned.
+	Date time.Time
+}
+
+// NoteEdited is published when staff edit a logged note about a user.
+type NoteEdited struct {
+	// Guild is the id of the server it happened in.
+	Guild string
+
+	// User is the user the note is about.
+	User *discordgo.User
+
+	// Staff is the staff member who edited the note.
+	Staff *discordgo.User
+
+	// Old is the note's text before it was edited.
+	Old string
+
+	// New is the note's text after it was edited.
+	New string
+}
+
+// DMReceived is published when a user sends the bot a direct message.
+type DMReceived struct {
+	// Message is the message they sent.
+	Message *discordgo.Message
+}
+
+// ConfigChanged is published when an owner changes a config field with the config command.
+type ConfigChanged struct {
+	// Staff is the owner who changed it.
+	Staff *discordgo.User
+
+	// Key is the path of the field.
+	Key string
+
+	// Old is the field's value before it was changed.
+	Old string
+
+	// New is the field's value after it was changed.
+	New string
+
+	// Reset is whether the field was reset to the config file's value, rather than set.
+	Reset bool
+}
@@ -0,0 +1,77 @@
+package fakediscord
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"golang.org/x/exp/maps"
+)
+
+// AddConfigured adds every server in the config, with the categories, channels and roles it refers to, so the bot's
+// config check finds everything. Channels are put in the server's first listening category. The bot owns every server,
+// so it has every permission. It returns the home server.
+func (d *Discord) AddConfigured(config *lib.Config) *discordgo.Guild {
+	var home *discordgo.Guild
+
+	for _, guildID := range config.GuildIDs() {
+		guildConfig := config.Guild(guildID)
+		if guildConfig == nil {
+			continue
+		}
+
+		name := guildID
+		if guildID == config.Servers.Home {
+			name = "home"
+		}
+		guild := d.AddGuildWithId(guildID, name)
+		if home == nil {
+			home = guild
+		}
+
+		// the same id can be configured more than once, e.g. one channel for both logs
+		added := make(map[string]bool)
+
+		category := ""
+		for _, id := range guildConfig.Categories.CommandsEnabled {
+			if !added[id] {
+				d.AddChannelWithId(id, guildID, "listening", discordgo.ChannelTypeGuildCategory, "")
+				added[id] = true
+			}
+			if category == "" {
+				category = id
+			}
+		}
+
+		channels := guildConfig.Channels
+		for name, id := range map[string]string{
+			"mailbox":    channels.Mailbox,
+			"spam":       channels.Spam,
+			"log":        channels.Log,
+			"syslog":     channels.SysLog,
+			"watchlist":  channels.Watchlist,
+			"ban-appeal": channels.BanAppeal,
+		} {
+			if id != "" && !added[id] {
+				d.AddChannelWithId(id, guildID, name, discordgo.ChannelTypeGuildText, category)
+				added[id] = true
+			}
+		}
+		for _, id := range channels.SpamIgnored {
+			if !added[id] {
+				d.AddChannelWithId(id, guildID, "spam-ignored", discordgo.ChannelTypeGuildText, category)
+				added[id] = true
+			}
+		}
+
+		roles := guildConfig.Roles
+		for _, ids := range append([][]string{roles.Admin, roles.Owner, roles.DMThread}, maps.Values(roles.Permissions)...) {
+			for _, id := range ids {
+				if !added[id] {
+					d.AddRoleWithId(id, guildID, id, 0)
+					added[id] = true
+				}
+			}
+		}
+	}
+
+	return home
+}
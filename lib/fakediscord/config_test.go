@@ -0,0 +1,62 @@
+package fakediscord
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"testing"
+)
+
+func TestAddConfigured(t *testing.T) {
+	config := &lib.Config{
+		Servers: lib.ServerConfig{Home: "100"},
+		GuildConfig: lib.GuildConfig{
+			Categories: lib.CategoryConfig{CommandsEnabled: []string{"300", "301"}},
+			Channels:   lib.ChannelConfig{Log: "310", SysLog: "310", SpamIgnored: []string{"311"}},
+			Roles:      lib.RoleConfig{Admin: []string{"400"}, Owner: []string{"400", "401"}},
+		},
+		Guilds: map[string]*lib.GuildConfig{
+			"101": {Channels: lib.ChannelConfig{Log: "320"}},
+		},
+	}
+
+	d := New("bot")
+	home := d.AddConfigured(config)
+	if home.ID != "100" {
+		t.Errorf("home = %s, want 100", home.ID)
+	}
+
+	channels, err := d.GuildChannels("100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 4 {
+		t.Errorf("%d channels in the home server, want 4 - each configured once", len(channels))
+	}
+
+	log, err := d.Channel("310")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log.ParentID != "300" || log.Type != discordgo.ChannelTypeGuildText {
+		t.Errorf("log channel = %+v, want a text channel in the first listening category", log)
+	}
+
+	if roles, _ := d.GuildRoles("100"); len(roles) != 2 {
+		t.Errorf("%d roles in the home server, want 2", len(roles))
+	}
+
+	if _, err = d.Channel("320"); err != nil {
+		t.Errorf("other server's channel wasn't added: %s", err)
+	}
+
+	// the bot owns every server
+	for _, id := range []string{"100", "101"} {
+		guild, err := d.Guild(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if guild.OwnerID != d.BotUser().ID {
+			t.Errorf("server %s is owned by %s", id, guild.OwnerID)
+		}
+	}
+}
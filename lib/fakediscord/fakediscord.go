@@ -0,0 +1,1033 @@
+// Package fakediscord is an in-memory fake of discord, so bot behaviour can be tested without a bot token.
+//
+// A Discord holds guilds with members, roles and channels, plus threads, DMs and bans. Messages the bot sends are
+// recorded so tests can check what it did, and events can be dispatched to the bot's handlers.
+package fakediscord
+
+import (
+	"errors"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Discord is an in-memory fake of discord. It implements lib.DiscordClient.
+// The zero value isn't usable - create one with New.
+type Discord struct {
+	// guards everything below
+	lock sync.Mutex
+
+	// the bot's user
+	botUser *discordgo.User
+
+	// last id handed out
+	lastId int64
+
+	// map from id to user
+	users map[string]*discordgo.User
+
+	// map from id to guild. Guilds hold their members, roles and (non-thread) channels.
+	guilds map[string]*discordgo.Guild
+
+	// map from id to channel, including threads and DMs
+	channels map[string]*discordgo.Channel
+
+	// map from channel id to messages in it, oldest first
+	messages map[string][]*discordgo.Message
+
+	// map from guild id to user id to ban
+	bans map[string]map[string]*discordgo.GuildBan
+
+	// map from guild id to user id to when their timeout ends
+	timeouts map[string]map[string]time.Time
+
+	// map from guild id to slash commands
+	commands map[string][]*discordgo.ApplicationCommand
+
+	// map from interaction id to its response message
+	responses map[string]*discordgo.Message
+
+	// map from interaction id to the flags its deferred response was acknowledged with
+	deferred map[string]discordgo.MessageFlags
+
+	// every message the bot has sent, in order
+	sent []*discordgo.Message
+
+	// registered event handlers
+	handlers []*handler
+
+	// whether the client is connected
+	open bool
+}
+
+// handler is a registered event handler.
+type handler struct {
+	fn   reflect.Value
+	once bool
+}
+
+var _ lib.DiscordClient = (*Discord)(nil)
+
+// New creates a fake with no guilds, and a bot user with the given name.
+func New(botName string) *Discord {
+	d := &Discord{
+		users:     make(map[string]*discordgo.User),
+		guilds:    make(map[string]*discordgo.Guild),
+		channels:  make(map[string]*discordgo.Channel),
+		messages:  make(map[string][]*discordgo.Message),
+		bans:      make(map[string]map[string]*discordgo.GuildBan),
+		timeouts:  make(map[string]map[string]time.Time),
+		commands:  make(map[string][]*discordgo.ApplicationCommand),
+		responses: make(map[string]*discordgo.Message),
+		deferred:  make(map[string]discordgo.MessageFlags),
+	}
+
+	d.botUser = d.AddUser(botName)
+	d.botUser.Bot = true
+
+	return d
+}
+
+// NewId returns a new unique snowflake.
+func (d *Discord) NewId() string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.newId()
+}
+
+// newId returns a new unique snowflake. The lock must be held.
+func (d *Discord) newId() string {
+	d.lastId++
+	return strconv.FormatInt(100000000000000000+d.lastId, 10)
+}
+
+// notFound returns the error discord returns when something doesn't exist.
+func notFound(what string) error {
+	return &discordgo.RESTError{
+		Response: &http.Response{StatusCode: http.StatusNotFound, Status: http.StatusText(http.StatusNotFound)},
+		Message:  &discordgo.APIErrorMessage{Message: "Unknown " + what},
+	}
+}
+
+// Setup
+
+// AddUser adds a user with a new id.
+func (d *Discord) AddUser(name string) *discordgo.User {
+	return d.AddUserWithId(d.NewId(), name)
+}
+
+// AddUserWithId adds a user with the id, e.g. to match one in a config.
+func (d *Discord) AddUserWithId(id, name string) *discordgo.User {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	user := &discordgo.User{ID: id, Username: name, Discriminator: "0"}
+	d.users[user.ID] = user
+
+	return user
+}
+
+// AddGuild adds a guild with a new id. The bot is added as a member.
+func (d *Discord) AddGuild(name string) *discordgo.Guild {
+	return d.AddGuildWithId(d.NewId(), name)
+}
+
+// AddGuildWithId adds a guild with the id, e.g. to match one in a config. The bot is added as a member.
+func (d *Discord) AddGuildWithId(id, name string) *discordgo.Guild {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	guild := &discordgo.Guild{ID: id, Name: name, OwnerID: d.botUser.ID}
+	guild.Members = append(guild.Members, &discordgo.Member{GuildID: guild.ID, User: d.botUser, JoinedAt: time.Now()})
+	d.guilds[guild.ID] = guild
+
+	return guild
+}
+
+// AddRole adds a role with a new id to the guild.
+func (d *Discord) AddRole(guildID, name string, permissions int64) *discordgo.Role {
+	return d.AddRoleWithId(d.NewId(), guildID, name, permissions)
+}
+
+// AddRoleWithId adds a role with the id to the guild, e.g. to match one in a config.
+func (d *Discord) AddRoleWithId(id, guildID, name string, permissions int64) *discordgo.Role {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	role := &discordgo.Role{ID: id, Name: name, Permissions: permissions}
+	d.guilds[guildID].Roles = append(d.guilds[guildID].Roles, role)
+
+	return role
+}
+
+// AddMember adds the user to the guild with the roles.
+func (d *Discord) AddMember(guildID string, user *discordgo.User, roleIDs ...string) *discordgo.Member {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	member := &discordgo.Member{GuildID: guildID, User: user, Roles: roleIDs, JoinedAt: time.Now()}
+	d.guilds[guildID].Members = append(d.guilds[guildID].Members, member)
+
+	return member
+}
+
+// AddChannel adds a channel with a new id to the guild. parentID is the category it's in, and may be empty.
+func (d *Discord) AddChannel(guildID, name string, channelType discordgo.ChannelType, parentID string) *discordgo.Channel {
+	return d.AddChannelWithId(d.NewId(), guildID, name, channelType, parentID)
+}
+
+// AddChannelWithId adds a channel with the id to the guild, e.g. to match one in a config. parentID is the category
+// it's in, and may be empty.
+func (d *Discord) AddChannelWithId(id, guildID, name string, channelType discordgo.ChannelType, parentID string) *discordgo.Channel {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	channel := &discordgo.Channel{ID: id, GuildID: guildID, Name: name, Type: channelType, ParentID: parentID}
+	d.channels[channel.ID] = channel
+	d.guilds[guildID].Channels = append(d.guilds[guildID].Channels, channel)
+
+	return channel
+}
+
+// Inspection
+
+// Sent returns every message the bot has sent, in order. Interaction responses are included.
+func (d *Discord) Sent() []*discordgo.Message {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return append([]*discordgo.Message(nil), d.sent...)
+}
+
+// Messages returns the messages currently in the channel, oldest first.
+func (d *Discord) Messages(channelID string) []*discordgo.Message {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return append([]*discordgo.Message(nil), d.messages[channelID]...)
+}
+
+// DMs returns the messages in the DM channel with the user, oldest first.
+func (d *Discord) DMs(userID string) []*discordgo.Message {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	channel := d.dmChannel(userID)
+	if channel == nil {
+		return nil
+	}
+
+	return append([]*discordgo.Message(nil), d.messages[channel.ID]...)
+}
+
+// Banned returns whether the user is banned from the guild.
+func (d *Discord) Banned(guildID, userID string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	_, ok := d.bans[guildID][userID]
+	return ok
+}
+
+// TimedOutUntil returns when the user's timeout in the guild ends, or the zero time if they aren't timed out.
+func (d *Discord) TimedOutUntil(guildID, userID string) time.Time {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.timeouts[guildID][userID]
+}
+
+// Commands returns the slash commands registered in the guild.
+func (d *Discord) Commands(guildID string) []*discordgo.ApplicationCommand {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.commands[guildID]
+}
+
+// ChannelName returns a readable name for the channel: #name, or DM @user for DMs. It returns the id if the channel
+// doesn't exist.
+func (d *Discord) ChannelName(channelID string) string {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	channel, ok := d.channels[channelID]
+	if !ok {
+		return channelID
+	}
+
+	if channel.Type == discordgo.ChannelTypeDM && len(channel.Recipients) == 1 {
+		return "DM @" + channel.Recipients[0].Username
+	}
+
+	return "#" + channel.Name
+}
+
+// Describe returns a readable version of the message: its content, followed by a line for each embed, attachment and
+// row of buttons.
+func Describe(message *discordgo.Message) string {
+	var lines []string
+	if message.Content != "" {
+		lines = append(lines, message.Content)
+	}
+
+	for _, embed := range message.Embeds {
+		lines = append(lines, strings.TrimSpace(embed.Title+"\n"+embed.Description))
+	}
+
+	for _, attachment := range message.Attachments {
+		lines = append(lines, fmt.Sprintf("(attached %s)", attachment.Filename))
+	}
+
+	for _, row := range message.Components {
+		if actions, ok := row.(discordgo.ActionsRow); ok {
+			var labels []string
+			for _, component := range actions.Components {
+				if button, ok := component.(discordgo.Button); ok {
+					labels = append(labels, button.Label)
+				}
+			}
+
+			lines = append(lines, fmt.Sprintf("(buttons: %s)", strings.Join(labels, ", ")))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// Events
+
+// Dispatch calls every registered handler for the event's type, as if discord had sent it. Handlers are called one
+// at a time, and Dispatch returns once they're all done.
+func (d *Discord) Dispatch(event interface{}) {
+	eventType := reflect.TypeOf(event)
+
+	d.lock.Lock()
+	var toCall []*handler
+	remaining := d.handlers[:0:0]
+	for _, h := range d.handlers {
+		paramType := h.fn.Type().In(1)
+		matches := paramType == eventType || (paramType.Kind() == reflect.Interface && eventType.Implements(paramType))
+		if matches {
+			toCall = append(toCall, h)
+		}
+
+		if !matches || !h.once {
+			remaining = append(remaining, h)
+		}
+	}
+	d.handlers = remaining
+	d.lock.Unlock()
+
+	args := []reflect.Value{reflect.Zero(reflect.TypeOf((*discordgo.Session)(nil))), reflect.ValueOf(event)}
+	for _, h := range toCall {
+		h.fn.Call(args)
+	}
+}
+
+// SendMessage dispatches a message being sent by the author in the channel, and returns it.
+func (d *Discord) SendMessage(author *discordgo.User, channelID, content string) *discordgo.Message {
+	d.lock.Lock()
+	message := d.newMessage(author, channelID, content)
+	d.lock.Unlock()
+
+	d.Dispatch(&discordgo.MessageCreate{Message: message})
+	return message
+}
+
+// SendDM dispatches a direct message being sent by the user to the bot, and returns it.
+func (d *Discord) SendDM(author *discordgo.User, content string) *discordgo.Message {
+	d.lock.Lock()
+	channel := d.getOrCreateDMChannel(author.ID)
+	message := d.newMessage(author, channel.ID, content)
+	d.lock.Unlock()
+
+	d.Dispatch(&discordgo.MessageCreate{Message: message})
+	return message
+}
+
+// Click dispatches the user clicking the button with the label on the message. guildID is the guild the user is
+// clicking as a member of.
+func (d *Discord) Click(user *discordgo.User, guildID string, message *discordgo.Message, label string) error {
+	for _, row := range message.Components {
+		actions, ok := row.(discordgo.ActionsRow)
+		if !ok {
+			continue
+		}
+
+		for _, component := range actions.Components {
+			button, ok := component.(discordgo.Button)
+			if !ok || button.Label != label {
+				continue
+			}
+
+			member, err := d.GuildMember(guildID, user.ID)
+			if err != nil {
+				return err
+			}
+
+			d.Dispatch(&discordgo.InteractionCreate{Interaction: &discordgo.Interaction{
+				ID:        d.NewId(),
+				Type:      discordgo.InteractionMessageComponent,
+				GuildID:   guildID,
+				ChannelID: message.ChannelID,
+				Message:   message,
+				Member:    member,
+				Data:      discordgo.MessageComponentInteractionData{CustomID: button.CustomID, ComponentType: discordgo.ButtonComponent},
+			}})
+			return nil
+		}
+	}
+
+	return errors.New("message has no button labelled " + label)
+}
+
+// SlashCommand dispatches the user running the slash command with the options in the channel, as a member of the
+// channel's guild, and returns the interaction.
+func (d *Discord) SlashCommand(user *discordgo.User, channelID, name string, options ...*discordgo.ApplicationCommandInteractionDataOption) (*discordgo.Interaction, error) {
+	channel, err := d.Channel(channelID)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := d.GuildMember(channel.GuildID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := &discordgo.Interaction{
+		ID:        d.NewId(),
+		Type:      discordgo.InteractionApplicationCommand,
+		GuildID:   channel.GuildID,
+		ChannelID: channelID,
+		Member:    member,
+		Data:      discordgo.ApplicationCommandInteractionData{ID: d.NewId(), Name: name, Options: options},
+	}
+
+	d.Dispatch(&discordgo.InteractionCreate{Interaction: interaction})
+	return interaction, nil
+}
+
+// newMessage creates a message and adds it to the channel. The lock must be held.
+func (d *Discord) newMessage(author *discordgo.User, channelID, content string) *discordgo.Message {
+	message := &discordgo.Message{
+		ID:        d.newId(),
+		ChannelID: channelID,
+		Content:   content,
+		Author:    author,
+		Timestamp: time.Now(),
+	}
+
+	if channel, ok := d.channels[channelID]; ok {
+		message.GuildID = channel.GuildID
+		if member := d.member(channel.GuildID, author.ID); member != nil {
+			message.Member = member
+		}
+	}
+
+	d.messages[channelID] = append(d.messages[channelID], message)
+	return message
+}
+
+// send records a message sent by the bot. The lock must be held.
+func (d *Discord) send(channelID string, data *discordgo.MessageSend) (*discordgo.Message, error) {
+	if _, ok := d.channels[channelID]; !ok {
+		return nil, notFound("Channel")
+	}
+
+	message := d.newMessage(d.botUser, channelID, data.Content)
+	message.Components = data.Components
+	message.Embeds = data.Embeds
+	for _, file := range data.Files {
+		message.Attachments = append(message.Attachments, &discordgo.MessageAttachment{ID: d.newId(), Filename: file.Name, ContentType: file.ContentType})
+	}
+
+	d.sent = append(d.sent, message)
+	return message, nil
+}
+
+// member returns the member of the guild, or nil. The lock must be held.
+func (d *Discord) member(guildID, userID string) *discordgo.Member {
+	guild, ok := d.guilds[guildID]
+	if !ok {
+		return nil
+	}
+
+	for _, member := range guild.Members {
+		if member.User.ID == userID {
+			return member
+		}
+	}
+
+	return nil
+}
+
+// dmChannel returns the DM channel with the user, or nil. The lock must be held.
+func (d *Discord) dmChannel(userID string) *discordgo.Channel {
+	for _, channel := range d.channels {
+		if channel.Type == discordgo.ChannelTypeDM && len(channel.Recipients) == 1 && channel.Recipients[0].ID == userID {
+			return channel
+		}
+	}
+
+	return nil
+}
+
+// getOrCreateDMChannel returns the DM channel with the user, creating it if needed. The lock must be held.
+func (d *Discord) getOrCreateDMChannel(userID string) *discordgo.Channel {
+	if channel := d.dmChannel(userID); channel != nil {
+		return channel
+	}
+
+	channel := &discordgo.Channel{ID: d.newId(), Type: discordgo.ChannelTypeDM, Recipients: []*discordgo.User{d.users[userID]}}
+	d.channels[channel.ID] = channel
+	return channel
+}
+
+// DiscordClient implementation
+
+func (d *Discord) AddHandler(fn interface{}) func() {
+	return d.addHandler(fn, false)
+}
+
+func (d *Discord) AddHandlerOnce(fn interface{}) func() {
+	return d.addHandler(fn, true)
+}
+
+func (d *Discord) addHandler(fn interface{}, once bool) func() {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func || value.Type().NumIn() != 2 {
+		panic(fmt.Sprintf("invalid handler type %T", fn))
+	}
+
+	h := &handler{fn: value, once: once}
+
+	d.lock.Lock()
+	d.handlers = append(d.handlers, h)
+	d.lock.Unlock()
+
+	return func() {
+		d.lock.Lock()
+		defer d.lock.Unlock()
+
+		for i, registered := range d.handlers {
+			if registered == h {
+				d.handlers = append(d.handlers[:i], d.handlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// Open connects, dispatching a ready event with every guild.
+func (d *Discord) Open() error {
+	d.lock.Lock()
+	if d.open {
+		d.lock.Unlock()
+		return errors.New("already open")
+	}
+	d.open = true
+
+	ready := &discordgo.Ready{SessionID: "fake", User: d.botUser}
+	for _, guild := range d.guilds {
+		ready.Guilds = append(ready.Guilds, guild)
+	}
+	d.lock.Unlock()
+
+	d.Dispatch(ready)
+	return nil
+}
+
+func (d *Discord) Close() error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	d.open = false
+	return nil
+}
+
+func (d *Discord) BotUser() *discordgo.User {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.botUser
+}
+
+func (d *Discord) User(userID string, _ ...discordgo.RequestOption) (*discordgo.User, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	user, ok := d.users[userID]
+	if !ok {
+		return nil, notFound("User")
+	}
+
+	return user, nil
+}
+
+func (d *Discord) Guild(guildID string, _ ...discordgo.RequestOption) (*discordgo.Guild, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	guild, ok := d.guilds[guildID]
+	if !ok {
+		return nil, notFound("Guild")
+	}
+
+	return guild, nil
+}
+
+func (d *Discord) GuildRoles(guildID string, _ ...discordgo.RequestOption) ([]*discordgo.Role, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	guild, ok := d.guilds[guildID]
+	if !ok {
+		return nil, notFound("Guild")
+	}
+
+	return guild.Roles, nil
+}
+
+func (d *Discord) GuildChannels(guildID string, _ ...discordgo.RequestOption) ([]*discordgo.Channel, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	guild, ok := d.guilds[guildID]
+	if !ok {
+		return nil, notFound("Guild")
+	}
+
+	return guild.Channels, nil
+}
+
+func (d *Discord) GuildMember(guildID, userID string, _ ...discordgo.RequestOption) (*discordgo.Member, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	member := d.member(guildID, userID)
+	if member == nil {
+		return nil, notFound("Member")
+	}
+
+	return member, nil
+}
+
+func (d *Discord) GuildMemberRoleAdd(guildID, userID, roleID string, _ ...discordgo.RequestOption) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	member := d.member(guildID, userID)
+	if member == nil {
+		return notFound("Member")
+	}
+
+	for _, role := range member.Roles {
+		if role == roleID {
+			return nil
+		}
+	}
+
+	member.Roles = append(member.Roles, roleID)
+	return nil
+}
+
+func (d *Discord) GuildMemberRoleRemove(guildID, userID, roleID string, _ ...discordgo.RequestOption) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	member := d.member(guildID, userID)
+	if member == nil {
+		return notFound("Member")
+	}
+
+	for i, role := range member.Roles {
+		if role == roleID {
+			member.Roles = append(member.Roles[:i], member.Roles[i+1:]...)
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (d *Discord) GuildBan(guildID, userID string, _ ...discordgo.RequestOption) (*discordgo.GuildBan, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	ban, ok := d.bans[guildID][userID]
+	if !ok {
+		return nil, notFound("Ban")
+	}
+
+	return ban, nil
+}
+
+func (d *Discord) GuildBanCreateWithReason(guildID, userID, reason string, _ int, _ ...discordgo.RequestOption) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	user, ok := d.users[userID]
+	if !ok {
+		return notFound("User")
+	}
+
+	if d.bans[guildID] == nil {
+		d.bans[guildID] = make(map[string]*discordgo.GuildBan)
+	}
+	d.bans[guildID][userID] = &discordgo.GuildBan{Reason: reason, User: user}
+
+	d.removeMember(guildID, userID)
+	return nil
+}
+
+func (d *Discord) GuildBanDelete(guildID, userID string, _ ...discordgo.RequestOption) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, ok := d.bans[guildID][userID]; !ok {
+		return notFound("Ban")
+	}
+
+	delete(d.bans[guildID], userID)
+	return nil
+}
+
+func (d *Discord) GuildMemberDeleteWithReason(guildID, userID, _ string, _ ...discordgo.RequestOption) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.member(guildID, userID) == nil {
+		return notFound("Member")
+	}
+
+	d.removeMember(guildID, userID)
+	return nil
+}
+
+// removeMember removes the user from the guild's members. The lock must be held.
+func (d *Discord) removeMember(guildID, userID string) {
+	guild, ok := d.guilds[guildID]
+	if !ok {
+		return
+	}
+
+	for i, member := range guild.Members {
+		if member.User.ID == userID {
+			guild.Members = append(guild.Members[:i], guild.Members[i+1:]...)
+			return
+		}
+	}
+}
+
+func (d *Discord) GuildMemberTimeout(guildID string, userID string, until *time.Time, _ ...discordgo.RequestOption) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	member := d.member(guildID, userID)
+	if member == nil {
+		return notFound("Member")
+	}
+
+	member.CommunicationDisabledUntil = until
+
+	if d.timeouts[guildID] == nil {
+		d.timeouts[guildID] = make(map[string]time.Time)
+	}
+	if until == nil {
+		delete(d.timeouts[guildID], userID)
+	} else {
+		d.timeouts[guildID][userID] = *until
+	}
+
+	return nil
+}
+
+func (d *Discord) Channel(channelID string, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	channel, ok := d.channels[channelID]
+	if !ok {
+		return nil, notFound("Channel")
+	}
+
+	return channel, nil
+}
+
+func (d *Discord) UserChannelCreate(recipientID string, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, ok := d.users[recipientID]; !ok {
+		return nil, notFound("User")
+	}
+
+	return d.getOrCreateDMChannel(recipientID), nil
+}
+
+func (d *Discord) ThreadStartComplex(channelID string, data *discordgo.ThreadStart, _ ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	parent, ok := d.channels[channelID]
+	if !ok {
+		return nil, notFound("Channel")
+	}
+
+	threadType := data.Type
+	if threadType == 0 {
+		threadType = discordgo.ChannelTypeGuildPublicThread
+	}
+
+	thread := &discordgo.Channel{
+		ID:       d.newId(),
+		GuildID:  parent.GuildID,
+		ParentID: parent.ID,
+		Name:     data.Name,
+		Type:     threadType,
+		ThreadMetadata: &discordgo.ThreadMetadata{
+			AutoArchiveDuration: data.AutoArchiveDuration,
+		},
+	}
+	d.channels[thread.ID] = thread
+
+	return thread, nil
+}
+
+func (d *Discord) ThreadMemberAdd(threadID, memberID string, _ ...discordgo.RequestOption) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	thread, ok := d.channels[threadID]
+	if !ok || !thread.IsThread() {
+		return notFound("Channel")
+	}
+
+	if _, ok := d.users[memberID]; !ok {
+		return notFound("User")
+	}
+
+	thread.Members = append(thread.Members, &discordgo.ThreadMember{ID: threadID, UserID: memberID, JoinTimestamp: time.Now()})
+	return nil
+}
+
+func (d *Discord) ChannelMessages(channelID string, limit int, beforeID, afterID, _ string, _ ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if _, ok := d.channels[channelID]; !ok {
+		return nil, notFound("Channel")
+	}
+
+	// newest first, like discord
+	var messages []*discordgo.Message
+	all := d.messages[channelID]
+	for i := len(all) - 1; i >= 0 && (limit <= 0 || len(messages) < limit); i-- {
+		id, _ := strconv.ParseInt(all[i].ID, 10, 64)
+		if before, err := strconv.ParseInt(beforeID, 10, 64); err == nil && id >= before {
+			continue
+		}
+		if after, err := strconv.ParseInt(afterID, 10, 64); err == nil && id <= after {
+			continue
+		}
+
+		messages = append(messages, all[i])
+	}
+
+	return messages, nil
+}
+
+func (d *Discord) ChannelMessageSend(channelID string, content string, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.send(channelID, &discordgo.MessageSend{Content: content})
+}
+
+func (d *Discord) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	return d.send(channelID, data)
+}
+
+func (d *Discord) ChannelMessageEditComplex(edit *discordgo.MessageEdit, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, message := range d.messages[edit.Channel] {
+		if message.ID != edit.ID {
+			continue
+		}
+
+		if edit.Content != nil {
+			message.Content = *edit.Content
+		}
+		if edit.Components != nil {
+			message.Components = edit.Components
+		}
+
+		return message, nil
+	}
+
+	return nil, notFound("Message")
+}
+
+func (d *Discord) ChannelMessageDelete(channelID, messageID string, _ ...discordgo.RequestOption) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	messages := d.messages[channelID]
+	for i, message := range messages {
+		if message.ID == messageID {
+			d.messages[channelID] = append(messages[:i], messages[i+1:]...)
+			return nil
+		}
+	}
+
+	return notFound("Message")
+}
+
+func (d *Discord) ApplicationCommandBulkOverwrite(_ string, guildID string, commands []*discordgo.ApplicationCommand, _ ...discordgo.RequestOption) ([]*discordgo.ApplicationCommand, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, command := range commands {
+		command.ID = d.newId()
+		command.GuildID = guildID
+	}
+	d.commands[guildID] = commands
+
+	return commands, nil
+}
+
+func (d *Discord) InteractionRespond(interaction *discordgo.Interaction, resp *discordgo.InteractionResponse, _ ...discordgo.RequestOption) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	data := resp.Data
+	if data == nil {
+		data = &discordgo.InteractionResponseData{}
+	}
+
+	switch resp.Type {
+	case discordgo.InteractionResponseChannelMessageWithSource:
+		message, err := d.send(interaction.ChannelID, &discordgo.MessageSend{Content: data.Content, Components: data.Components, Embeds: data.Embeds})
+		if err != nil {
+			return err
+		}
+
+		message.Flags = data.Flags
+		d.responses[interaction.ID] = message
+	case discordgo.InteractionResponseDeferredChannelMessageWithSource:
+		// nothing's sent until the response is edited
+		d.deferred[interaction.ID] = data.Flags
+	case discordgo.InteractionResponseUpdateMessage:
+		if interaction.Message == nil {
+			return errors.New("interaction has no message to update")
+		}
+
+		for _, message := range d.messages[interaction.ChannelID] {
+			if message.ID == interaction.Message.ID {
+				message.Content = data.Content
+				message.Components = data.Components
+			}
+		}
+	}
+
+	return nil
+}
+
+func (d *Discord) InteractionResponseEdit(interaction *discordgo.Interaction, newresp *discordgo.WebhookEdit, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	message, ok := d.responses[interaction.ID]
+	if !ok {
+		var err error
+		message, err = d.send(interaction.ChannelID, &discordgo.MessageSend{})
+		if err != nil {
+			return nil, err
+		}
+
+		message.Flags = d.deferred[interaction.ID]
+		d.responses[interaction.ID] = message
+	}
+
+	d.applyWebhookEdit(message, newresp)
+	return message, nil
+}
+
+// applyWebhookEdit changes the message as discord would for the edit. The lock must be held.
+func (d *Discord) applyWebhookEdit(message *discordgo.Message, edit *discordgo.WebhookEdit) {
+	if edit.Content != nil {
+		message.Content = *edit.Content
+	}
+	if edit.Components != nil {
+		message.Components = *edit.Components
+	}
+	if edit.Embeds != nil {
+		message.Embeds = *edit.Embeds
+	}
+	for _, file := range edit.Files {
+		message.Attachments = append(message.Attachments, &discordgo.MessageAttachment{ID: d.newId(), Filename: file.Name, ContentType: file.ContentType})
+	}
+}
+
+func (d *Discord) InteractionResponseDelete(interaction *discordgo.Interaction, _ ...discordgo.RequestOption) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	message, ok := d.responses[interaction.ID]
+	if !ok {
+		// a deferred response that was never edited
+		return nil
+	}
+
+	delete(d.responses, interaction.ID)
+
+	messages := d.messages[message.ChannelID]
+	for i, existing := range messages {
+		if existing == message {
+			d.messages[message.ChannelID] = append(messages[:i], messages[i+1:]...)
+			break
+		}
+	}
+
+	return nil
+}
+
+func (d *Discord) FollowupMessageCreate(interaction *discordgo.Interaction, _ bool, data *discordgo.WebhookParams, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	message, err := d.send(interaction.ChannelID, &discordgo.MessageSend{Content: data.Content, Components: data.Components, Embeds: data.Embeds, Files: data.Files})
+	if err != nil {
+		return nil, err
+	}
+
+	message.Flags = data.Flags
+	return message, nil
+}
+
+func (d *Discord) FollowupMessageEdit(interaction *discordgo.Interaction, messageID string, data *discordgo.WebhookEdit, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	for _, message := range d.messages[interaction.ChannelID] {
+		if message.ID == messageID {
+			d.applyWebhookEdit(message, data)
+			return message, nil
+		}
+	}
+
+	return nil, notFound("Message")
+}
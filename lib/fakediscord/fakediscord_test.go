@@ -0,0 +1,243 @@
+package fakediscord
+
+import (
+	"errors"
+	"github.com/bwmarrin/discordgo"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// isNotFound returns whether the error is the one discord returns when something doesn't exist.
+func isNotFound(err error) bool {
+	var restErr *discordgo.RESTError
+	return errors.As(err, &restErr) && restErr.Response.StatusCode == http.StatusNotFound
+}
+
+func TestMessages(t *testing.T) {
+	d := New("bot")
+	guild := d.AddGuild("guild")
+	channel := d.AddChannel(guild.ID, "general", discordgo.ChannelTypeGuildText, "")
+	user := d.AddUser("user")
+	d.AddMember(guild.ID, user)
+
+	received := d.SendMessage(user, channel.ID, "hi")
+	if received.GuildID != guild.ID || received.Member == nil {
+		t.Errorf("message = %+v, want it in the guild with member info", received)
+	}
+
+	sent, err := d.ChannelMessageSendComplex(channel.ID, &discordgo.MessageSend{
+		Content: "hello",
+		Files:   []*discordgo.File{{Name: "a.log", ContentType: "text/plain", Reader: strings.NewReader("a")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sent.Author.ID != d.BotUser().ID || len(sent.Attachments) != 1 || sent.Attachments[0].Filename != "a.log" {
+		t.Errorf("sent = %+v, want from the bot with the attachment", sent)
+	}
+
+	content := "edited"
+	if _, err = d.ChannelMessageEditComplex(&discordgo.MessageEdit{ID: sent.ID, Channel: channel.ID, Content: &content}); err != nil {
+		t.Fatal(err)
+	}
+
+	// only the bot's messages are recorded as sent, and edits change the recorded message
+	all := d.Sent()
+	if len(all) != 1 || all[0].Content != "edited" {
+		t.Errorf("sent = %+v, want only the edited bot message", all)
+	}
+
+	messages, err := d.ChannelMessages(channel.ID, 1, "", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 1 || messages[0].ID != sent.ID {
+		t.Errorf("newest message = %+v, want the bot's", messages)
+	}
+
+	if messages, _ = d.ChannelMessages(channel.ID, 0, sent.ID, "", ""); len(messages) != 1 || messages[0].ID != received.ID {
+		t.Errorf("messages before the bot's = %+v, want the user's", messages)
+	}
+
+	if err = d.ChannelMessageDelete(channel.ID, received.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Messages(channel.ID); len(got) != 1 {
+		t.Errorf("%d messages left, want 1", len(got))
+	}
+
+	if _, err = d.ChannelMessageSend("missing", "hi"); !isNotFound(err) {
+		t.Errorf("sending to a missing channel: err = %v, want not found", err)
+	}
+}
+
+func TestDMs(t *testing.T) {
+	d := New("bot")
+	user := d.AddUser("user")
+
+	received := d.SendDM(user, "help")
+	if received.GuildID != "" {
+		t.Errorf("DM is in guild %q", received.GuildID)
+	}
+
+	channel, err := d.UserChannelCreate(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if channel.ID != received.ChannelID {
+		t.Errorf("DM channel = %s, want the one the DM was sent in (%s)", channel.ID, received.ChannelID)
+	}
+
+	if _, err = d.ChannelMessageSend(channel.ID, "hi"); err != nil {
+		t.Fatal(err)
+	}
+
+	dms := d.DMs(user.ID)
+	if len(dms) != 2 || dms[0].Content != "help" || dms[1].Content != "hi" {
+		t.Errorf("DMs = %+v, want help then hi", dms)
+	}
+
+	if got := d.ChannelName(channel.ID); got != "DM @user" {
+		t.Errorf("channel name = %q", got)
+	}
+}
+
+func TestBans(t *testing.T) {
+	d := New("bot")
+	guild := d.AddGuild("guild")
+	user := d.AddUser("user")
+	d.AddMember(guild.ID, user)
+
+	if _, err := d.GuildBan(guild.ID, user.ID); !isNotFound(err) {
+		t.Errorf("ban before banning: err = %v, want not found", err)
+	}
+
+	if err := d.GuildBanCreateWithReason(guild.ID, user.ID, "spam", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	ban, err := d.GuildBan(guild.ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ban.Reason != "spam" || ban.User.ID != user.ID {
+		t.Errorf("ban = %+v, want the user for spam", ban)
+	}
+
+	// banning removes the member
+	if _, err = d.GuildMember(guild.ID, user.ID); !isNotFound(err) {
+		t.Errorf("banned member: err = %v, want not found", err)
+	}
+
+	if err = d.GuildBanDelete(guild.ID, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if d.Banned(guild.ID, user.ID) {
+		t.Error("user is still banned")
+	}
+	if err = d.GuildBanDelete(guild.ID, user.ID); !isNotFound(err) {
+		t.Errorf("unbanning twice: err = %v, want not found", err)
+	}
+
+	if err = d.GuildBanCreateWithReason(guild.ID, "missing", "spam", 0); !isNotFound(err) {
+		t.Errorf("banning a missing user: err = %v, want not found", err)
+	}
+}
+
+func TestKickAndTimeout(t *testing.T) {
+	d := New("bot")
+	guild := d.AddGuild("guild")
+	user := d.AddUser("user")
+	d.AddMember(guild.ID, user)
+
+	until := time.Now().Add(time.Hour)
+	if err := d.GuildMemberTimeout(guild.ID, user.ID, &until); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.TimedOutUntil(guild.ID, user.ID); !got.Equal(until) {
+		t.Errorf("timed out until %s, want %s", got, until)
+	}
+
+	if err := d.GuildMemberTimeout(guild.ID, user.ID, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := d.TimedOutUntil(guild.ID, user.ID); !got.IsZero() {
+		t.Errorf("timed out until %s, want no timeout", got)
+	}
+
+	if err := d.GuildMemberDeleteWithReason(guild.ID, user.ID, "bye"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.GuildMemberDeleteWithReason(guild.ID, user.ID, "bye"); !isNotFound(err) {
+		t.Errorf("kicking twice: err = %v, want not found", err)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	d := New("bot")
+	guild := d.AddGuild("guild")
+	role := d.AddRole(guild.ID, "role", 0)
+	user := d.AddUser("user")
+	d.AddMember(guild.ID, user)
+
+	// adding a role twice only adds it once
+	for i := 0; i < 2; i++ {
+		if err := d.GuildMemberRoleAdd(guild.ID, user.ID, role.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	member, err := d.GuildMember(guild.ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(member.Roles) != 1 || member.Roles[0] != role.ID {
+		t.Errorf("roles = %q, want [%s]", member.Roles, role.ID)
+	}
+
+	if err = d.GuildMemberRoleRemove(guild.ID, user.ID, role.ID); err != nil {
+		t.Fatal(err)
+	}
+	if member, _ = d.GuildMember(guild.ID, user.ID); len(member.Roles) != 0 {
+		t.Errorf("roles = %q, want none", member.Roles)
+	}
+
+	if err = d.GuildMemberRoleAdd(guild.ID, "missing", role.ID); !isNotFound(err) {
+		t.Errorf("adding a role to a missing member: err = %v, want not found", err)
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	d := New("bot")
+	guild := d.AddGuild("guild")
+
+	var ready, creates, once int
+	d.AddHandler(func(_ *discordgo.Session, event *discordgo.Ready) {
+		ready++
+		if len(event.Guilds) != 1 || event.Guilds[0].ID != guild.ID {
+			t.Errorf("ready guilds = %+v, want the guild", event.Guilds)
+		}
+	})
+	remove := d.AddHandler(func(_ *discordgo.Session, _ *discordgo.MessageCreate) { creates++ })
+	d.AddHandlerOnce(func(_ *discordgo.Session, _ *discordgo.MessageCreate) { once++ })
+
+	if err := d.Open(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Open(); err == nil {
+		t.Error("opened twice")
+	}
+
+	channel := d.AddChannel(guild.ID, "general", discordgo.ChannelTypeGuildText, "")
+	user := d.AddUser("user")
+
+	d.SendMessage(user, channel.ID, "a")
+	remove()
+	d.SendMessage(user, channel.ID, "b")
+
+	if ready != 1 || creates != 1 || once != 1 {
+		t.Errorf("ready = %d, creates = %d, once = %d, want 1 each", ready, creates, once)
+	}
+}
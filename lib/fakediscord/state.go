@@ -0,0 +1,222 @@
+package fakediscord
+
+import (
+	"github.com/bwmarrin/discordgo"
+)
+
+// Apply updates the fake from an event discord sent, the way discordgo's state tracking does - e.g. adding the guilds
+// in a ready event, or the message in a message create event. It doesn't dispatch the event.
+// This lets recorded events be replayed against the fake without setting it up first.
+func (d *Discord) Apply(event interface{}) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	switch e := event.(type) {
+	case *discordgo.Ready:
+		d.botUser = e.User
+		d.users[e.User.ID] = e.User
+		for _, guild := range e.Guilds {
+			d.putGuild(guild)
+		}
+
+	case *discordgo.GuildCreate:
+		d.putGuild(e.Guild)
+	case *discordgo.GuildUpdate:
+		d.putGuild(e.Guild)
+	case *discordgo.GuildDelete:
+		if e.Unavailable {
+			return
+		}
+		for id, channel := range d.channels {
+			if channel.GuildID == e.ID {
+				delete(d.channels, id)
+			}
+		}
+		delete(d.guilds, e.ID)
+
+	case *discordgo.GuildMemberAdd:
+		d.putMember(e.Member)
+	case *discordgo.GuildMemberUpdate:
+		d.putMember(e.Member)
+	case *discordgo.GuildMemberRemove:
+		d.removeMember(e.GuildID, e.User.ID)
+
+	case *discordgo.GuildRoleCreate:
+		d.putRole(e.GuildID, e.Role)
+	case *discordgo.GuildRoleUpdate:
+		d.putRole(e.GuildID, e.Role)
+	case *discordgo.GuildRoleDelete:
+		if guild, ok := d.guilds[e.GuildID]; ok {
+			for i, role := range guild.Roles {
+				if role.ID == e.RoleID {
+					guild.Roles = append(guild.Roles[:i], guild.Roles[i+1:]...)
+					break
+				}
+			}
+		}
+
+	case *discordgo.GuildBanAdd:
+		if d.bans[e.GuildID] == nil {
+			d.bans[e.GuildID] = make(map[string]*discordgo.GuildBan)
+		}
+		d.bans[e.GuildID][e.User.ID] = &discordgo.GuildBan{User: e.User}
+	case *discordgo.GuildBanRemove:
+		delete(d.bans[e.GuildID], e.User.ID)
+
+	case *discordgo.ChannelCreate:
+		d.putChannel(e.Channel)
+	case *discordgo.ChannelUpdate:
+		d.putChannel(e.Channel)
+	case *discordgo.ChannelDelete:
+		d.removeChannel(e.Channel)
+	case *discordgo.ThreadCreate:
+		d.putChannel(e.Channel)
+	case *discordgo.ThreadUpdate:
+		d.putChannel(e.Channel)
+	case *discordgo.ThreadDelete:
+		d.removeChannel(e.Channel)
+
+	case *discordgo.MessageCreate:
+		d.putMessage(e.Message)
+	case *discordgo.MessageUpdate:
+		for _, message := range d.messages[e.ChannelID] {
+			if message.ID == e.ID {
+				message.Content = e.Content
+				message.Embeds = e.Embeds
+				message.EditedTimestamp = e.EditedTimestamp
+			}
+		}
+	case *discordgo.MessageDelete:
+		messages := d.messages[e.ChannelID]
+		for i, message := range messages {
+			if message.ID == e.ID {
+				d.messages[e.ChannelID] = append(messages[:i], messages[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// putGuild adds or updates the guild. Members, roles and channels are kept if the update doesn't include them.
+// The lock must be held.
+func (d *Discord) putGuild(guild *discordgo.Guild) {
+	if existing, ok := d.guilds[guild.ID]; ok {
+		if guild.Members == nil {
+			guild.Members = existing.Members
+		}
+		if guild.Roles == nil {
+			guild.Roles = existing.Roles
+		}
+		if guild.Channels == nil {
+			guild.Channels = existing.Channels
+		}
+	}
+	d.guilds[guild.ID] = guild
+
+	for _, member := range guild.Members {
+		member.GuildID = guild.ID
+		d.users[member.User.ID] = member.User
+	}
+
+	for _, channel := range guild.Channels {
+		channel.GuildID = guild.ID
+		d.channels[channel.ID] = channel
+	}
+
+	for _, thread := range guild.Threads {
+		thread.GuildID = guild.ID
+		d.channels[thread.ID] = thread
+	}
+}
+
+// putMember adds or replaces the member in its guild. The lock must be held.
+func (d *Discord) putMember(member *discordgo.Member) {
+	guild, ok := d.guilds[member.GuildID]
+	if !ok {
+		return
+	}
+
+	d.users[member.User.ID] = member.User
+
+	for i, existing := range guild.Members {
+		if existing.User.ID == member.User.ID {
+			guild.Members[i] = member
+			return
+		}
+	}
+
+	guild.Members = append(guild.Members, member)
+}
+
+// putRole adds or replaces the role in the guild. The lock must be held.
+func (d *Discord) putRole(guildID string, role *discordgo.Role) {
+	guild, ok := d.guilds[guildID]
+	if !ok {
+		return
+	}
+
+	for i, existing := range guild.Roles {
+		if existing.ID == role.ID {
+			guild.Roles[i] = role
+			return
+		}
+	}
+
+	guild.Roles = append(guild.Roles, role)
+}
+
+// putChannel adds or replaces the channel. Channels that aren't threads are also put in their guild.
+// The lock must be held.
+func (d *Discord) putChannel(channel *discordgo.Channel) {
+	d.channels[channel.ID] = channel
+
+	guild, ok := d.guilds[channel.GuildID]
+	if !ok || channel.IsThread() {
+		return
+	}
+
+	for i, existing := range guild.Channels {
+		if existing.ID == channel.ID {
+			guild.Channels[i] = channel
+			return
+		}
+	}
+
+	guild.Channels = append(guild.Channels, channel)
+}
+
+// removeChannel removes the channel, and from its guild if it's in one. The lock must be held.
+func (d *Discord) removeChannel(channel *discordgo.Channel) {
+	delete(d.channels, channel.ID)
+
+	guild, ok := d.guilds[channel.GuildID]
+	if !ok {
+		return
+	}
+
+	for i, existing := range guild.Channels {
+		if existing.ID == channel.ID {
+			guild.Channels = append(guild.Channels[:i], guild.Channels[i+1:]...)
+			return
+		}
+	}
+}
+
+// putMessage adds the message to its channel. If it has member info for the author that isn't known yet, they're
+// added to the guild. The lock must be held.
+func (d *Discord) putMessage(message *discordgo.Message) {
+	if message.Author != nil {
+		if _, ok := d.users[message.Author.ID]; !ok {
+			d.users[message.Author.ID] = message.Author
+		}
+
+		if message.Member != nil && message.GuildID != "" && d.member(message.GuildID, message.Author.ID) == nil {
+			member := *message.Member
+			member.GuildID = message.GuildID
+			member.User = message.Author
+			d.putMember(&member)
+		}
+	}
+
+	d.messages[message.ChannelID] = append(d.messages[message.ChannelID], message)
+}
@@ -0,0 +1,205 @@
+package fakediscord
+
+import (
+	"github.com/bwmarrin/discordgo"
+	"testing"
+)
+
+func TestApplyReady(t *testing.T) {
+	d := New("bot")
+
+	bot := &discordgo.User{ID: "1", Username: "recorded", Bot: true}
+	user := &discordgo.User{ID: "2", Username: "user"}
+	d.Apply(&discordgo.Ready{
+		User: bot,
+		Guilds: []*discordgo.Guild{{
+			ID:       "10",
+			Name:     "guild",
+			Members:  []*discordgo.Member{{User: user, Roles: []string{"20"}}},
+			Roles:    []*discordgo.Role{{ID: "20", Name: "role"}},
+			Channels: []*discordgo.Channel{{ID: "30", Name: "general"}},
+			Threads:  []*discordgo.Channel{{ID: "31", Name: "thread", ParentID: "30", Type: discordgo.ChannelTypeGuildPublicThread}},
+		}},
+	})
+
+	if got := d.BotUser(); got.ID != bot.ID {
+		t.Errorf("bot user = %s, want %s", got.ID, bot.ID)
+	}
+
+	member, err := d.GuildMember("10", user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member.GuildID != "10" || len(member.Roles) != 1 {
+		t.Errorf("member = %+v, want in guild 10 with the role", member)
+	}
+
+	for _, id := range []string{"30", "31"} {
+		channel, err := d.Channel(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if channel.GuildID != "10" {
+			t.Errorf("channel %s is in guild %q, want 10", id, channel.GuildID)
+		}
+	}
+
+	// a guild update without members, roles or channels keeps the ones already known
+	d.Apply(&discordgo.GuildUpdate{Guild: &discordgo.Guild{ID: "10", Name: "renamed"}})
+
+	guild, err := d.Guild("10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if guild.Name != "renamed" || len(guild.Members) != 1 || len(guild.Roles) != 1 || len(guild.Channels) != 1 {
+		t.Errorf("guild = %+v, want renamed with its members, roles and channels", guild)
+	}
+
+	// unavailable guilds are kept, deleted ones are removed with their channels
+	d.Apply(&discordgo.GuildDelete{Guild: &discordgo.Guild{ID: "10", Unavailable: true}})
+	if _, err = d.Guild("10"); err != nil {
+		t.Errorf("unavailable guild was removed: %s", err)
+	}
+
+	d.Apply(&discordgo.GuildDelete{Guild: &discordgo.Guild{ID: "10"}})
+	if _, err = d.Guild("10"); err == nil {
+		t.Error("deleted guild still exists")
+	}
+	if _, err = d.Channel("30"); err == nil {
+		t.Error("deleted guild's channel still exists")
+	}
+}
+
+func TestApplyMembersAndRoles(t *testing.T) {
+	d := New("bot")
+	guild := d.AddGuild("guild")
+	user := &discordgo.User{ID: "2", Username: "user"}
+
+	d.Apply(&discordgo.GuildMemberAdd{Member: &discordgo.Member{GuildID: guild.ID, User: user}})
+	d.Apply(&discordgo.GuildRoleCreate{GuildRole: &discordgo.GuildRole{GuildID: guild.ID, Role: &discordgo.Role{ID: "20", Name: "role"}}})
+	d.Apply(&discordgo.GuildMemberUpdate{Member: &discordgo.Member{GuildID: guild.ID, User: user, Roles: []string{"20"}}})
+
+	member, err := d.GuildMember(guild.ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(member.Roles) != 1 || member.Roles[0] != "20" {
+		t.Errorf("roles = %q, want [20]", member.Roles)
+	}
+
+	if _, err = d.User(user.ID); err != nil {
+		t.Errorf("member's user wasn't added: %s", err)
+	}
+
+	d.Apply(&discordgo.GuildRoleUpdate{GuildRole: &discordgo.GuildRole{GuildID: guild.ID, Role: &discordgo.Role{ID: "20", Name: "renamed"}}})
+	roles, err := d.GuildRoles(guild.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roles) != 1 || roles[0].Name != "renamed" {
+		t.Errorf("roles = %+v, want one renamed role", roles)
+	}
+
+	d.Apply(&discordgo.GuildRoleDelete{GuildID: guild.ID, RoleID: "20"})
+	if roles, _ = d.GuildRoles(guild.ID); len(roles) != 0 {
+		t.Errorf("roles = %+v, want none", roles)
+	}
+
+	d.Apply(&discordgo.GuildMemberRemove{Member: &discordgo.Member{GuildID: guild.ID, User: user}})
+	if _, err = d.GuildMember(guild.ID, user.ID); err == nil {
+		t.Error("removed member still exists")
+	}
+}
+
+func TestApplyBans(t *testing.T) {
+	d := New("bot")
+	guild := d.AddGuild("guild")
+	user := d.AddUser("user")
+
+	d.Apply(&discordgo.GuildBanAdd{GuildID: guild.ID, User: user})
+	if !d.Banned(guild.ID, user.ID) {
+		t.Error("user wasn't banned")
+	}
+
+	d.Apply(&discordgo.GuildBanRemove{GuildID: guild.ID, User: user})
+	if d.Banned(guild.ID, user.ID) {
+		t.Error("user is still banned")
+	}
+}
+
+func TestApplyChannels(t *testing.T) {
+	d := New("bot")
+	guild := d.AddGuild("guild")
+
+	channel := &discordgo.Channel{ID: "30", GuildID: guild.ID, Name: "general"}
+	thread := &discordgo.Channel{ID: "31", GuildID: guild.ID, ParentID: "30", Type: discordgo.ChannelTypeGuildPublicThread}
+
+	d.Apply(&discordgo.ChannelCreate{Channel: channel})
+	d.Apply(&discordgo.ThreadCreate{Channel: thread})
+	d.Apply(&discordgo.ChannelUpdate{Channel: &discordgo.Channel{ID: "30", GuildID: guild.ID, Name: "renamed"}})
+
+	// threads aren't guild channels
+	channels, err := d.GuildChannels(guild.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 1 || channels[0].Name != "renamed" {
+		t.Errorf("channels = %+v, want only the renamed channel", channels)
+	}
+
+	if _, err = d.Channel(thread.ID); err != nil {
+		t.Errorf("thread wasn't added: %s", err)
+	}
+
+	d.Apply(&discordgo.ThreadDelete{Channel: thread})
+	d.Apply(&discordgo.ChannelDelete{Channel: channel})
+
+	if channels, _ = d.GuildChannels(guild.ID); len(channels) != 0 {
+		t.Errorf("channels = %+v, want none", channels)
+	}
+	if _, err = d.Channel(thread.ID); err == nil {
+		t.Error("deleted thread still exists")
+	}
+}
+
+func TestApplyMessages(t *testing.T) {
+	d := New("bot")
+	guild := d.AddGuild("guild")
+	channel := d.AddChannel(guild.ID, "general", discordgo.ChannelTypeGuildText, "")
+
+	// the author's member info is added if it isn't known yet
+	author := &discordgo.User{ID: "2", Username: "user"}
+	d.Apply(&discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        "40",
+		ChannelID: channel.ID,
+		GuildID:   guild.ID,
+		Author:    author,
+		Member:    &discordgo.Member{Roles: []string{"20"}},
+		Content:   "hi",
+	}})
+
+	member, err := d.GuildMember(guild.ID, author.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if member.User != author || len(member.Roles) != 1 {
+		t.Errorf("member = %+v, want the author with their roles", member)
+	}
+
+	d.Apply(&discordgo.MessageUpdate{Message: &discordgo.Message{ID: "40", ChannelID: channel.ID, Content: "edited"}})
+
+	messages := d.Messages(channel.ID)
+	if len(messages) != 1 || messages[0].Content != "edited" {
+		t.Fatalf("messages = %+v, want the edited message", messages)
+	}
+
+	d.Apply(&discordgo.MessageDelete{Message: &discordgo.Message{ID: "40", ChannelID: channel.ID}})
+	if messages = d.Messages(channel.ID); len(messages) != 0 {
+		t.Errorf("messages = %+v, want none", messages)
+	}
+
+	// applying doesn't record the message as sent by the bot
+	if sent := d.Sent(); len(sent) != 0 {
+		t.Errorf("sent = %+v, want none", sent)
+	}
+}
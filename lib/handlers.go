@@ -0,0 +1,98 @@
+package lib
+
+import (
+	uuid2 "github.com/google/uuid"
+	"reflect"
+	"runtime/debug"
+	"strings"
+	"sync"
+)
+
+// panicCounter counts panics in event handlers per component.
+type panicCounter struct {
+	// guards counts
+	lock sync.Mutex
+
+	// map from component name to number of panics
+	counts map[string]int
+}
+
+func newPanicCounter() *panicCounter {
+	return &panicCounter{counts: make(map[string]int)}
+}
+
+// ForComponent returns utils for the named component. Logs include the component name, and panics in event handlers
+// registered with AddHandler are counted against it.
+func (u *Utils) ForComponent(name string) *Utils {
+	utils := u.NewWithLog(AddString("component", name))
+	utils.component = name
+	return utils
+}
+
+// componentName returns the name of a component: its type name in lowercase.
+func componentName(component Component) string {
+	t := reflect.TypeOf(component)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	return strings.ToLower(t.Name())
+}
+
+// AddHandler registers a discord event handler, like discordgo.Session.AddHandler, but recovers from panics in the
+// handler so they can't crash the bot. Panics are logged with a UUID and the event type, and counted against the
+// component. It returns a function that removes the handler.
+func (u *Utils) AddHandler(handler interface{}) func() {
+	value := reflect.ValueOf(handler)
+	handlerType := value.Type()
+
+	// let discordgo deal with invalid handlers
+	if handlerType.Kind() != reflect.Func || handlerType.NumIn() != 2 {
+		return u.Discord.AddHandler(handler)
+	}
+
+	eventType := handlerType.In(1).String()
+
+	wrapped := reflect.MakeFunc(handlerType, func(args []reflect.Value) []reflect.Value {
+		defer u.recoverHandler(eventType)
+
+		return value.Call(args)
+	})
+
+	return u.Discord.AddHandler(wrapped.Interface())
+}
+
+// recoverHandler recovers from a panic in a handler for the event type, logging it with a UUID and counting it against
+// the component. It must be deferred.
+func (u *Utils) recoverHandler(eventType string) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	u.panics.lock.Lock()
+	u.panics.counts[u.component]++
+	count := u.panics.counts[u.component]
+	u.panics.lock.Unlock()
+
+	u.Log.Error().
+		Str("uuid", uuid2.New().String()).
+		Str("event", eventType).
+		Int("count", count).
+		Any("panic", r).
+		Str("stack", string(debug.Stack())).
+		Msg("event handler panicked")
+}
+
+// PanicCounts returns the number of panics in event handlers registered with AddHandler, per component.
+func (u *Utils) PanicCounts() map[string]int {
+	u.panics.lock.Lock()
+	defer u.panics.lock.Unlock()
+
+	counts := make(map[string]int, len(u.panics.counts))
+	for component, count := range u.panics.counts {
+		counts[component] = count
+	}
+
+	return counts
+}
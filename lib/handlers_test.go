@@ -0,0 +1,62 @@
+package lib_test
+
+import (
+	"bytes"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/rs/zerolog"
+	"strings"
+	"testing"
+)
+
+// panicker is a component with a message handler which panics, registered before one which doesn't.
+type panicker struct {
+	handled []string
+}
+
+func (p *panicker) Setup(utils *lib.Utils) error {
+	utils.AddHandler(func(_ *discordgo.Session, m *discordgo.MessageCreate) {
+		if m.Content == "panic" {
+			panic("oh no")
+		}
+	})
+
+	utils.AddHandler(func(_ *discordgo.Session, m *discordgo.MessageCreate) {
+		p.handled = append(p.handled, m.Content)
+	})
+
+	return nil
+}
+
+func TestAddHandlerRecoversPanics(t *testing.T) {
+	component := &panicker{}
+	bot, fake := newBot(t, &lib.Config{Servers: lib.ServerConfig{Home: "1"}}, component)
+
+	var logs bytes.Buffer
+	bot.Log = zerolog.New(&logs)
+
+	if err := bot.Start(fake); err != nil {
+		t.Fatal(err)
+	}
+	defer bot.Stop()
+
+	channel := fake.AddChannel("1", "general", discordgo.ChannelTypeGuildText, "")
+	user := fake.AddUser("user")
+	fake.SendMessage(user, channel.ID, "panic")
+	fake.SendMessage(user, channel.ID, "hello")
+
+	// the other handlers still get the event that caused the panic, and later events
+	if got := strings.Join(component.handled, ","); got != "panic,hello" {
+		t.Errorf("handled = %s, want panic,hello", got)
+	}
+
+	if counts := bot.PanicCounts(); len(counts) != 1 || counts["panicker"] != 1 {
+		t.Errorf("panic counts = %v, want 1 for panicker", counts)
+	}
+
+	for _, want := range []string{`"message":"event handler panicked"`, `"event":"*discordgo.MessageCreate"`, `"panic":"oh no"`, `"component":"panicker"`, `"uuid":`} {
+		if !strings.Contains(logs.String(), want) {
+			t.Errorf("logs don't contain %s:\n%s", want, logs.String())
+		}
+	}
+}
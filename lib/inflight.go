@@ -0,0 +1,76 @@
+package lib
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// inFlight tracks work in progress, e.g. commands being run, so the bot can cancel it and wait for it to finish when
+// shutting down.
+type inFlight struct {
+	// cancelled when the bot shuts down
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// guards stopping, and adding to wg once stopping
+	lock sync.Mutex
+
+	// whether the bot is shutting down - no new work is started once it is
+	stopping bool
+
+	// counts work in progress
+	wg sync.WaitGroup
+}
+
+func newInFlight() *inFlight {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &inFlight{ctx: ctx, cancel: cancel}
+}
+
+// Begin marks the start of work the bot should wait for before shutting down. It returns a context which is cancelled
+// after timeout or when the bot shuts down, and a function that must be called when the work is done.
+// If the bot is already shutting down, the context is already cancelled.
+func (u *Utils) Begin(timeout time.Duration) (context.Context, func()) {
+	f := u.inFlight
+
+	f.lock.Lock()
+	stopping := f.stopping
+	if !stopping {
+		f.wg.Add(1)
+	}
+	f.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(f.ctx, timeout)
+	if stopping {
+		cancel()
+		return ctx, func() {}
+	}
+
+	return ctx, func() {
+		cancel()
+		f.wg.Done()
+	}
+}
+
+// drain cancels all work in progress, and waits up to timeout for it to finish. It returns whether it all finished.
+func (f *inFlight) drain(timeout time.Duration) bool {
+	f.lock.Lock()
+	f.stopping = true
+	f.lock.Unlock()
+
+	f.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		f.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,183 @@
+package lib
+
+import (
+	"context"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	uuid2 "github.com/google/uuid"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ComponentHandler handles a message component interaction, e.g. a button click. action is the part of the custom id
+// after the handler's key.
+type ComponentHandler func(interaction *discordgo.Interaction, action string)
+
+// ComponentRouter routes message component interactions to handlers based on their custom id.
+// Custom ids are of the form key:action - the key picks the handler, and the action is passed to it.
+type ComponentRouter struct {
+	// guards handlers
+	lock sync.Mutex
+
+	// map from key to handler
+	handlers map[string]ComponentHandler
+}
+
+// NewComponentRouter creates a router with no handlers.
+func NewComponentRouter() *ComponentRouter {
+	return &ComponentRouter{handlers: make(map[string]ComponentHandler)}
+}
+
+// CustomId returns the custom id to use for a component that should be routed to the handler for key.
+func CustomId(key, action string) string {
+	return key + ":" + action
+}
+
+// Handle registers a handler for components with custom ids created using the key.
+func (r *ComponentRouter) Handle(key string, handler ComponentHandler) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.handlers[key] = handler
+}
+
+// Remove removes the handler for the key.
+func (r *ComponentRouter) Remove(key string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.handlers, key)
+}
+
+// Setup registers the router with discord.
+func (r *ComponentRouter) Setup(utils *Utils) {
+	utils.AddHandler(func(_ *discordgo.Session, interactionCreate *discordgo.InteractionCreate) {
+		if interactionCreate.Type != discordgo.InteractionMessageComponent {
+			return
+		}
+
+		r.route(utils, interactionCreate.Interaction)
+	})
+}
+
+// route calls the handler for the interaction.
+func (r *ComponentRouter) route(utils *Utils, interaction *discordgo.Interaction) {
+	customId := interaction.MessageComponentData().CustomID
+
+	key, action, _ := strings.Cut(customId, ":")
+
+	r.lock.Lock()
+	handler, ok := r.handlers[key]
+	r.lock.Unlock()
+
+	if !ok {
+		// e.g. the bot restarted since the component was sent
+		utils.Log.Debug().Str("id", customId).Msg("no handler for component")
+
+		err := utils.RespondEphemeral(interaction, "This has expired.")
+		if err != nil {
+			utils.Log.Error().Err(err).Msg("failed to respond to component interaction")
+		}
+		return
+	}
+
+	handler(interaction, action)
+}
+
+// RespondEphemeral responds to an interaction with a message only the user who triggered it can see.
+func (u *Utils) RespondEphemeral(interaction *discordgo.Interaction, message string) error {
+	return u.Discord.InteractionRespond(interaction, &discordgo.InteractionResponse{
+		Type: discordgo.InteractionResponseChannelMessageWithSource,
+		Data: &discordgo.InteractionResponseData{
+			Content: message,
+			Flags:   discordgo.MessageFlagsEphemeral,
+		},
+	})
+}
+
+const (
+	confirmAction = "confirm"
+	cancelAction  = "cancel"
+)
+
+// Confirm replies to replyTo with the summary and Confirm/Cancel buttons, and waits for the user
+// with userId to click one. It returns whether they confirmed - if they cancel, don't click either button within
+// timeout, or ctx is cancelled, it returns false.
+func (u *Utils) Confirm(ctx context.Context, replyTo *discordgo.Message, userId, summary string, timeout time.Duration) (bool, error) {
+	key := "confirm-" + uuid2.New().String()
+
+	result := make(chan bool, 1)
+	u.Components.Handle(key, func(interaction *discordgo.Interaction, action string) {
+		user := interaction.User
+		if interaction.Member != nil {
+			user = interaction.Member.User
+		}
+
+		if user == nil || user.ID != userId {
+			err := u.RespondEphemeral(interaction, fmt.Sprintf("Only <@%s> can confirm this.", userId))
+			if err != nil {
+				u.Log.Error().Err(err).Msg("failed to respond to component interaction")
+			}
+			return
+		}
+
+		confirmed := action == confirmAction
+
+		outcome := "Cancelled."
+		if confirmed {
+			outcome = "Confirmed."
+		}
+
+		err := u.Discord.InteractionRespond(interaction, &discordgo.InteractionResponse{
+			Type: discordgo.InteractionResponseUpdateMessage,
+			Data: &discordgo.InteractionResponseData{
+				Content:    summary + "\n\n" + outcome,
+				Components: []discordgo.MessageComponent{},
+			},
+		})
+		if err != nil {
+			u.Log.Error().Err(err).Msg("failed to update confirmation message")
+		}
+
+		select {
+		case result <- confirmed:
+		default:
+			// already answered
+		}
+	})
+	defer u.Components.Remove(key)
+
+	message, err := u.ReplyComplex(replyTo, &discordgo.MessageSend{
+		Content: summary,
+		Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{
+				discordgo.Button{Label: "Confirm", Style: discordgo.DangerButton, CustomID: CustomId(key, confirmAction)},
+				discordgo.Button{Label: "Cancel", Style: discordgo.SecondaryButton, CustomID: CustomId(key, cancelAction)},
+			}},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+
+	outcome := "Timed out."
+	select {
+	case confirmed := <-result:
+		return confirmed, nil
+	case <-ctx.Done():
+		outcome = "Cancelled."
+	case <-time.After(timeout):
+	}
+
+	content := summary + "\n\n" + outcome
+	err = u.EditReply(message, &discordgo.MessageEdit{
+		Content:    &content,
+		Components: []discordgo.MessageComponent{},
+	})
+	if err != nil {
+		u.Log.Error().Err(err).Msg("failed to update unanswered confirmation message")
+	}
+
+	return false, nil
+}
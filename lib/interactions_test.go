@@ -0,0 +1,190 @@
+package lib_test
+
+import (
+	"context"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/harness"
+	"github.com/danvolchek/bouncer-go/lib"
+	"testing"
+	"time"
+)
+
+// prompt waits for the bot's last message in the channel to have buttons, and returns it.
+func prompt(t *testing.T, h *harness.Harness, channelID string) *discordgo.Message {
+	t.Helper()
+
+	var found *discordgo.Message
+	ok := h.WaitFor(func() bool {
+		messages := h.Discord.Messages(channelID)
+		if len(messages) == 0 {
+			return false
+		}
+
+		last := messages[len(messages)-1]
+		if len(last.Components) == 0 {
+			return false
+		}
+
+		found = last
+		return true
+	})
+	if !ok {
+		t.Fatal("no message with buttons was posted")
+	}
+
+	return found
+}
+
+// confirm runs Confirm in the background, returning a channel which receives its result.
+func confirm(h *harness.Harness, ctx context.Context, replyTo *discordgo.Message, timeout time.Duration) <-chan bool {
+	result := make(chan bool, 1)
+	go func() {
+		confirmed, err := h.Bot.Confirm(ctx, replyTo, replyTo.Author.ID, "Really?", timeout)
+		if err != nil {
+			panic(err)
+		}
+
+		result <- confirmed
+	}()
+
+	return result
+}
+
+func TestConfirm(t *testing.T) {
+	tests := []struct {
+		label   string
+		want    bool
+		outcome string
+	}{
+		{"Confirm", true, "Really?\n\nConfirmed."},
+		{"Cancel", false, "Really?\n\nCancelled."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.label, func(t *testing.T) {
+			h, err := harness.New(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close()
+
+			replyTo := h.SayIn(h.Staff, h.Channels.General.ID, "hi")
+			result := confirm(h, context.Background(), replyTo, time.Minute)
+
+			message := prompt(t, h, h.Channels.General.ID)
+
+			// only the user being asked can answer
+			if err = h.Click(h.Owner, message, test.label); err != nil {
+				t.Fatal(err)
+			}
+
+			if err = h.Click(h.Staff, message, test.label); err != nil {
+				t.Fatal(err)
+			}
+
+			select {
+			case confirmed := <-result:
+				if confirmed != test.want {
+					t.Errorf("confirmed = %v, want %v", confirmed, test.want)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Confirm didn't return")
+			}
+
+			if message.Content != test.outcome || len(message.Components) != 0 {
+				t.Errorf("prompt = %q with %d components, want %q with none", message.Content, len(message.Components), test.outcome)
+			}
+
+			posts := h.Posts(h.Channels.General.ID)
+			if want := "Only <@" + h.Staff.ID + "> can confirm this."; len(posts) != 2 || posts[1] != want {
+				t.Errorf("posts = %q, want the prompt and %q", posts, want)
+			}
+		})
+	}
+}
+
+func TestConfirmUnanswered(t *testing.T) {
+	tests := []struct {
+		name    string
+		cancel  bool
+		outcome string
+	}{
+		{"timeout", false, "Really?\n\nTimed out."},
+		{"cancelled", true, "Really?\n\nCancelled."},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, err := harness.New(t.TempDir())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer h.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			timeout := time.Minute
+			if !test.cancel {
+				timeout = 10 * time.Millisecond
+			}
+
+			replyTo := h.SayIn(h.Staff, h.Channels.General.ID, "hi")
+			result := confirm(h, ctx, replyTo, timeout)
+
+			if test.cancel {
+				prompt(t, h, h.Channels.General.ID)
+				cancel()
+			}
+
+			if confirmed := <-result; confirmed {
+				t.Error("confirmed without an answer")
+			}
+
+			messages := h.Discord.Messages(h.Channels.General.ID)
+			message := messages[len(messages)-1]
+
+			if message.Content != test.outcome || len(message.Components) != 0 {
+				t.Errorf("prompt = %q with %d components, want %q with none", message.Content, len(message.Components), test.outcome)
+			}
+		})
+	}
+}
+
+func TestComponentRouter(t *testing.T) {
+	h, err := harness.New(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+
+	var actions []string
+	h.Bot.Components.Handle("test", func(interaction *discordgo.Interaction, action string) {
+		actions = append(actions, action)
+	})
+
+	button := func(customId string) *discordgo.Message {
+		return &discordgo.Message{ChannelID: h.Channels.General.ID, Components: []discordgo.MessageComponent{
+			discordgo.ActionsRow{Components: []discordgo.MessageComponent{discordgo.Button{Label: "go", CustomID: customId}}},
+		}}
+	}
+
+	for _, customId := range []string{lib.CustomId("test", "a"), lib.CustomId("test", "b:c"), lib.CustomId("other", "a")} {
+		if err = h.Click(h.Staff, button(customId), "go"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h.Bot.Components.Remove("test")
+	if err = h.Click(h.Staff, button(lib.CustomId("test", "d")), "go"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(actions) != 2 || actions[0] != "a" || actions[1] != "b:c" {
+		t.Errorf("actions = %q, want [a b:c]", actions)
+	}
+
+	if posts := h.Posts(h.Channels.General.ID); len(posts) != 2 || posts[0] != "This has expired." || posts[1] != "This has expired." {
+		t.Errorf("posts = %q, want two expired replies", posts)
+	}
+}
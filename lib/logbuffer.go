@@ -0,0 +1,111 @@
+package lib
+
+import (
+	"encoding/json"
+	"github.com/rs/zerolog"
+	"io"
+	"sync"
+)
+
+// LogBuffer keeps the most recent log events in memory, indexed by the uuid field attached to them (see NewWithLog),
+// so the logs for e.g. a command can be looked up later. It's an io.Writer zerolog can write to.
+type LogBuffer struct {
+	// guards everything below
+	lock sync.Mutex
+
+	// ring buffer of events, written to in order of seq
+	events []logEvent
+
+	// seq of the next event to be written
+	next uint64
+
+	// map from uuid to the seqs of events with that uuid, oldest first
+	byUUID map[string][]uint64
+}
+
+// logEvent is a single log event.
+type logEvent struct {
+	seq  uint64
+	uuid string
+	data []byte
+}
+
+// NewLogBuffer creates a buffer that holds up to size events.
+func NewLogBuffer(size int) *LogBuffer {
+	return &LogBuffer{
+		events: make([]logEvent, size),
+		byUUID: make(map[string][]uint64),
+	}
+}
+
+// Write stores a single JSON log event.
+func (l *LogBuffer) Write(p []byte) (int, error) {
+	var fields struct {
+		UUID string `json:"uuid"`
+	}
+
+	// Not all events have a uuid, and those are still kept. Events that aren't valid JSON can't be logged by zerolog.
+	_ = json.Unmarshal(p, &fields)
+
+	// zerolog reuses p after this returns
+	data := make([]byte, len(p))
+	copy(data, p)
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	slot := &l.events[l.next%uint64(len(l.events))]
+
+	// drop the event being overwritten from the index - it's always the oldest one with its uuid
+	if slot.data != nil && slot.uuid != "" {
+		seqs := l.byUUID[slot.uuid][1:]
+		if len(seqs) == 0 {
+			delete(l.byUUID, slot.uuid)
+		} else {
+			l.byUUID[slot.uuid] = seqs
+		}
+	}
+
+	*slot = logEvent{seq: l.next, uuid: fields.UUID, data: data}
+	if fields.UUID != "" {
+		l.byUUID[fields.UUID] = append(l.byUUID[fields.UUID], l.next)
+	}
+
+	l.next++
+
+	return len(p), nil
+}
+
+// ByUUID returns the JSON events with the uuid still in the buffer, oldest first.
+func (l *LogBuffer) ByUUID(uuid string) [][]byte {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	var events [][]byte
+	for _, seq := range l.byUUID[uuid] {
+		events = append(events, l.events[seq%uint64(len(l.events))].data)
+	}
+
+	return events
+}
+
+// LevelFilter is a zerolog.LevelWriter which only passes events at Level or above on to Writer. It lets the LogBuffer
+// keep debug events for $logs while the console only shows the configured level.
+type LevelFilter struct {
+	Writer io.Writer
+	Level  zerolog.Level
+}
+
+// Write passes on an event whose level isn't known.
+func (l LevelFilter) Write(p []byte) (int, error) {
+	return l.Writer.Write(p)
+}
+
+// WriteLevel passes on the event if it's at Level or above.
+func (l LevelFilter) WriteLevel(level zerolog.Level, p []byte) (int, error) {
+	if level < l.Level {
+		return len(p), nil
+	}
+
+	return l.Writer.Write(p)
+}
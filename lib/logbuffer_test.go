@@ -0,0 +1,51 @@
+package lib
+
+import (
+	"bytes"
+	"github.com/rs/zerolog"
+	"testing"
+)
+
+func TestLogBuffer(t *testing.T) {
+	logs := NewLogBuffer(3)
+	log := zerolog.New(logs)
+
+	log.Info().Str("uuid", "a").Msg("1")
+	log.Info().Msg("2")
+	log.Info().Str("uuid", "b").Msg("3")
+	log.Info().Str("uuid", "a").Msg("4")
+
+	if events := logs.ByUUID("a"); len(events) != 1 || !bytes.Contains(events[0], []byte(`"message":"4"`)) {
+		t.Errorf("a = %q, want only the newest event", events)
+	}
+
+	if events := logs.ByUUID("b"); len(events) != 1 {
+		t.Errorf("b = %q, want one event", events)
+	}
+
+	log.Info().Msg("5")
+	log.Info().Msg("6")
+
+	if events := logs.ByUUID("b"); len(events) != 0 {
+		t.Errorf("b = %q, want it to have been overwritten", events)
+	}
+}
+
+func TestLevelFilter(t *testing.T) {
+	logs := NewLogBuffer(10)
+	var console bytes.Buffer
+
+	log := zerolog.New(zerolog.MultiLevelWriter(LevelFilter{Writer: &console, Level: zerolog.InfoLevel}, logs)).
+		Level(zerolog.DebugLevel)
+
+	log.Debug().Str("uuid", "a").Msg("debug")
+	log.Info().Str("uuid", "a").Msg("info")
+
+	if events := logs.ByUUID("a"); len(events) != 2 {
+		t.Errorf("buffer has %d events, want both", len(events))
+	}
+
+	if bytes.Contains(console.Bytes(), []byte("debug")) || !bytes.Contains(console.Bytes(), []byte("info")) {
+		t.Errorf("console = %q, want only the info event", console.String())
+	}
+}
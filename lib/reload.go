@@ -0,0 +1,72 @@
+package lib
+
+import (
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// configPollInterval is how often the config file is checked for changes. Tests shorten it.
+var configPollInterval = 5 * time.Second
+
+// watchConfig reloads the config from the file when it changes or SIGHUP is received, until stop is closed.
+func (b *Bot) watchConfig(path string, stop <-chan struct{}) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	lastModified := modTime(path)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-hup:
+			b.Log.Info().Msg("Received SIGHUP, reloading config.")
+		case <-ticker.C:
+			modified := modTime(path)
+			if modified.Equal(lastModified) {
+				continue
+			}
+			lastModified = modified
+
+			b.Log.Info().Msg("Config file changed, reloading config.")
+		}
+
+		b.reloadConfigFile(path)
+	}
+}
+
+// reloadConfigFile loads the config file and reloads it. If there are problems, they're logged and the current config
+// is kept.
+func (b *Bot) reloadConfigFile(path string) {
+	config, err := LoadConfig(path, b.Log)
+	if err == nil {
+		err = b.ReloadConfig(config)
+	}
+
+	if err != nil {
+		for _, problem := range strings.Split(err.Error(), "\n") {
+			b.Log.Error().Msg(problem)
+		}
+		b.Log.Error().Msg("failed to reload config - keeping the current config")
+		return
+	}
+
+	b.Log.Info().Msg("Config reloaded.")
+}
+
+// modTime returns when the file was last modified, or the zero time if it can't be read.
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
@@ -0,0 +1,168 @@
+package lib
+
+import (
+	"bytes"
+	"github.com/rs/zerolog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a buffer that's safe to log to from another goroutine while it's being read.
+type syncBuffer struct {
+	lock   sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.buffer.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.buffer.String()
+}
+
+// watchedBot returns a bot running with the config, which is watched for changes until the test ends.
+func watchedBot(t *testing.T, config string) (*Bot, string, *syncBuffer) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadConfig(path, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logs := &syncBuffer{}
+	bot := NewBot(nil, loaded, nil, zerolog.New(logs), NewLogBuffer(10))
+
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		bot.watchConfig(path, stop)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		close(stop)
+		<-stopped
+	})
+
+	return bot, path, logs
+}
+
+// waitFor calls f until it returns true, failing the test if that takes too long.
+func waitFor(t *testing.T, what string, f func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for !f() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// shortenPolling makes the config file be checked for changes often, until the test ends.
+func shortenPolling(t *testing.T) {
+	interval := configPollInterval
+	configPollInterval = 10 * time.Millisecond
+	t.Cleanup(func() { configPollInterval = interval })
+}
+
+// touch changes the file's modification time, so a change is noticed whenever watching started.
+func touch(t *testing.T, path string, i int) {
+	t.Helper()
+
+	modified := time.Now().Add(time.Duration(i) * time.Second)
+	if err := os.Chtimes(path, modified, modified); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadConfigWhenFileChanges(t *testing.T) {
+	shortenPolling(t)
+	bot, path, logs := watchedBot(t, `{"discord": "token", "command_prefix": "$", "servers": {"home": "100"}}`)
+
+	err := os.WriteFile(path, []byte(`{"discord": "token", "command_prefix": "!", "servers": {"home": "100"}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := 0
+	waitFor(t, "the config to be reloaded", func() bool {
+		i++
+		touch(t, path, i)
+		return bot.Config().Prefix == "!"
+	})
+
+	if !strings.Contains(logs.String(), "Config file changed, reloading config.") {
+		t.Errorf("logs = %s, want the change logged", logs)
+	}
+}
+
+func TestReloadConfigOnSIGHUP(t *testing.T) {
+	// SIGHUP would stop the test if nothing was listening for it
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	// the file is only checked every few seconds, so the reload is from the signal
+	bot, path, logs := watchedBot(t, `{"discord": "token", "command_prefix": "$", "servers": {"home": "100"}}`)
+
+	err := os.WriteFile(path, []byte(`{"discord": "token", "command_prefix": "!", "servers": {"home": "100"}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sent until it's noticed, since the bot may not be listening for it yet
+	waitFor(t, "the config to be reloaded", func() bool {
+		if err := syscall.Kill(os.Getpid(), syscall.SIGHUP); err != nil {
+			t.Fatal(err)
+		}
+		return bot.Config().Prefix == "!"
+	})
+
+	if !strings.Contains(logs.String(), "Received SIGHUP, reloading config.") {
+		t.Errorf("logs = %s, want the signal logged", logs)
+	}
+}
+
+func TestReloadConfigRejectsTokenChange(t *testing.T) {
+	shortenPolling(t)
+	bot, path, logs := watchedBot(t, `{"discord": "token", "command_prefix": "$", "servers": {"home": "100"}}`)
+
+	err := os.WriteFile(path, []byte(`{"discord": "other token", "command_prefix": "!", "servers": {"home": "100"}}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	i := 0
+	waitFor(t, "the reload to fail", func() bool {
+		i++
+		touch(t, path, i)
+		return strings.Contains(logs.String(), "failed to reload config - keeping the current config")
+	})
+
+	if !strings.Contains(logs.String(), "the bot token can't be changed without restarting") {
+		t.Errorf("logs = %s, want the token change to be the problem", logs)
+	}
+
+	// nothing in the new config is used
+	if config := bot.Config(); config.Token != "token" || config.Prefix != "$" {
+		t.Errorf("token, prefix = %q, %q, want the current config kept", config.Token, config.Prefix)
+	}
+}
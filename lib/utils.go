@@ -8,29 +8,119 @@ import (
 	"github.com/rs/zerolog"
 	"gorm.io/gorm"
 	"regexp"
+	"sync/atomic"
 )
 
 type Utils struct {
-	Discord *discordgo.Session
+	Discord DiscordClient
 
-	Config *Config
+	// the current config, shared by every copy of these utils so it can be swapped when reloaded
+	config *atomic.Pointer[Config]
+
+	// checks the config as it was last loaded again and swaps it in
+	refreshConfig func() error
 
 	Log zerolog.Logger
 
+	// LogLevel is the level logs are shown at. Debug events are kept in Logs whatever it is, so check this rather than
+	// whether Log has debug enabled to decide if debug details should be shown. Set it before the bot starts.
+	LogLevel zerolog.Level
+
+	// Logs holds recent log events, so they can be looked up by uuid.
+	Logs *LogBuffer
+
 	DB *gorm.DB
 
+	// Bus is where bot events are published and subscribed to.
+	Bus *Bus
+
+	// Components routes message component interactions, e.g. button clicks, to handlers.
+	Components *ComponentRouter
+
+	// name of the component these utils are for, if any
+	component string
+
+	// panics in event handlers, per component
+	panics *panicCounter
+
+	// work in progress, waited on when shutting down
+	inFlight *inFlight
+
+	// replier overrides how replies are sent, if set
+	replier Replier
+
 	userIdToReplyThreadId *lruCache[string, string]
 	replyThreadIdToUserId *lruCache[string, string]
 }
 
+// Config returns the current config. It may be replaced when the config file is reloaded, so don't hold on to it - call
+// this each time instead.
+func (u *Utils) Config() *Config {
+	return u.config.Load()
+}
+
+// RefreshConfig checks the config as it was last loaded again and swaps it in, telling components it changed. Use it
+// after changing something components apply to the config when checking it, e.g. config overrides.
+func (u *Utils) RefreshConfig() error {
+	if u.refreshConfig == nil {
+		return errors.New("the config can't be refreshed")
+	}
+
+	return u.refreshConfig()
+}
+
+// Repo returns the typed database API, using these utils' database connection. Prefer it to querying tables directly.
+func (u *Utils) Repo() *database.Repository {
+	return database.NewRepository(u.DB, u.Config().Servers.Home)
+}
+
+// Replier sends replies some other way than as channel messages. This is used when the message being replied to didn't
+// come from a channel, e.g. for slash commands.
+type Replier interface {
+	// Reply sends a message in reply to another.
+	Reply(replyTo *discordgo.Message, message *discordgo.MessageSend) (*discordgo.Message, error)
+
+	// Edit edits a message sent with Reply.
+	Edit(sent *discordgo.Message, edit *discordgo.MessageEdit) error
+}
+
+// WithReplier returns utils which send replies using the replier instead of as channel messages.
+func (u *Utils) WithReplier(replier Replier) *Utils {
+	utils := *u
+	utils.replier = replier
+	return &utils
+}
+
 // Reply sends a message in reply to another. It doesn't use the discord reply functionality.
 func (u *Utils) Reply(replyTo *discordgo.Message, message string) {
-	_, err := u.Discord.ChannelMessageSend(replyTo.ChannelID, message)
+	_, err := u.ReplyComplex(replyTo, &discordgo.MessageSend{Content: message})
 	if err != nil {
 		u.Log.Error().Msgf("failed to send reply: %s", err)
 	}
 }
 
+// ReplyComplex sends a message with buttons, files etc. in reply to another, and returns it so it can be edited with
+// EditReply.
+func (u *Utils) ReplyComplex(replyTo *discordgo.Message, message *discordgo.MessageSend) (*discordgo.Message, error) {
+	if u.replier != nil {
+		return u.replier.Reply(replyTo, message)
+	}
+
+	return u.Discord.ChannelMessageSendComplex(replyTo.ChannelID, message)
+}
+
+// EditReply edits a message sent with ReplyComplex.
+func (u *Utils) EditReply(sent *discordgo.Message, edit *discordgo.MessageEdit) error {
+	if u.replier != nil {
+		return u.replier.Edit(sent, edit)
+	}
+
+	edit.ID = sent.ID
+	edit.Channel = sent.ChannelID
+	_, err := u.Discord.ChannelMessageEditComplex(edit)
+	return err
+}
+
 var snowflakeRegexp = regexp.MustCompile(`^\d+$`)
 
 // UserFromId returns a user struct from a user id.
@@ -46,7 +136,7 @@ func (u *Utils) UserFromId(userId string) (*discordgo.User, error) {
 // UserFromName returns a user struct from a user name. The user must be present in the guild provided, and the bot must have
 // access to it's members.
 func (u *Utils) UserFromName(userName, guildId string) (*discordgo.User, error) {
-	guild, err := u.Discord.State.Guild(guildId)
+	guild, err := u.Discord.Guild(guildId)
 	if err != nil {
 		return nil, err
 	}
@@ -1,23 +1,36 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"github.com/danvolchek/bouncer-go/commands"
+	"github.com/danvolchek/bouncer-go/console"
 	"github.com/danvolchek/bouncer-go/database"
 	"github.com/danvolchek/bouncer-go/lib"
 	"github.com/danvolchek/bouncer-go/lib/components"
+	"github.com/danvolchek/bouncer-go/replay"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"gorm.io/gorm"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 )
 
+// logBufferSize is the number of recent log events kept in memory for $logs.
+const logBufferSize = 10000
+
 func main() {
 	configPath := flag.String("config", "", "path to config directory (required)")
 	debug := flag.Bool("debug", false, "sets log level to debug")
 	trace := flag.Bool("trace", false, "sets log level to trace")
+	consoleMode := flag.Bool("console", false, "runs commands read from stdin instead of connecting to discord")
+	consoleUser := flag.String("console-user", "", "id of the staff member to run console commands as (default is the first owner)")
+	checkConfig := flag.Bool("check-config", false, "validates the config file and exits without connecting")
+	dryRun := flag.Bool("dry-run", false, "only observes: nothing is changed in discord and a copy of the database is used")
+	record := flag.String("record", "", "path of a file to record gateway events to, for -replay")
+	replayPath := flag.String("replay", "", "path of recorded gateway events to replay against a fake discord and scratch database")
+	migrateOnly := flag.Bool("migrate-only", false, "migrates the database to the latest schema and exits without connecting")
 
 	// parse args
 	{
@@ -30,62 +43,143 @@ func main() {
 	}
 
 	// set up logging
+	logs := lib.NewLogBuffer(logBufferSize)
+	sysLog := components.NewSysLog()
+	logLevel := zerolog.InfoLevel
 	{
-		log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.DateOnly + " " + time.Kitchen + " MST"}).
-			With().Caller().
-			Logger()
-
-		logLevel := zerolog.InfoLevel
 		if *trace {
 			logLevel = zerolog.TraceLevel
 		} else if *debug {
 			logLevel = zerolog.DebugLevel
 		}
 
-		zerolog.SetGlobalLevel(logLevel)
+		console := lib.LevelFilter{
+			Writer: zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.DateOnly + " " + time.Kitchen + " MST"},
+			Level:  logLevel,
+		}
+
+		log.Logger = log.Output(zerolog.MultiLevelWriter(console, logs, sysLog)).
+			With().Caller().
+			Logger()
+
+		// debug events are always kept in logs for $logs, only the console is limited to logLevel
+		if logLevel > zerolog.DebugLevel {
+			zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		} else {
+			zerolog.SetGlobalLevel(logLevel)
+		}
 		log.Info().Msgf("Log level is %s", logLevel)
 	}
 
 	// Load config
+	configFile := filepath.Join(*configPath, "config.json")
 	var config *lib.Config
 	{
-		configFile := filepath.Join(*configPath, "config.json")
-		data, err := os.ReadFile(configFile)
+		var err error
+		config, err = lib.LoadConfig(configFile, log.Logger)
 		if err != nil {
-			log.Fatal().Err(err).Msg("failed to read config file")
+			for _, problem := range strings.Split(err.Error(), "\n") {
+				log.Error().Msg(problem)
+			}
+			log.Fatal().Msg("invalid config file")
 		}
 
-		err = json.Unmarshal(data, &config)
+		if *checkConfig {
+			if config.Token == "" {
+				log.Warn().Msg("No bot token is configured - one is needed to connect to discord.")
+			}
+			log.Info().Msg("Config file is valid.")
+			return
+		}
+
+		if *dryRun {
+			config.DryRun = true
+		}
+	}
+
+	// create components
+	comps, err := components.All(commands.All, sysLog)
+	if err != nil {
+		log.Fatal().Err(err).Msg("failed to create components")
+	}
+
+	// replay recorded events
+	if *replayPath != "" {
+		err = replay.Run(comps, config, log.Logger, logs, *replayPath, os.Stdout)
 		if err != nil {
-			log.Fatal().Err(err).Msg("failed to parse config file")
+			log.Fatal().Err(err).Msg("failed to replay events")
 		}
+
+		return
 	}
 
 	dbFile := filepath.Join(*configPath, "bouncer.db")
+
+	// migrate the real database, even in dry run mode, which would only migrate a copy
+	if *migrateOnly {
+		db, err := database.New(dbFile, config.Servers.Home)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to migrate database")
+		}
+
+		version, err := database.Version(db)
+		_ = database.Close(db)
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to get schema version")
+		}
+
+		log.Info().Msgf("Database is at schema version %d.", version)
+		return
+	}
+
 	// Initialize database
-	db, err := database.New(dbFile)
+	var db *gorm.DB
+	if config.DryRun {
+		dryRunDbFile := filepath.Join(*configPath, "bouncer.dry-run.db")
+		log.Info().Msgf("Dry run - using a copy of the database at %s", dryRunDbFile)
+		db, err = database.NewCopy(dbFile, dryRunDbFile, config.Servers.Home)
+	} else {
+		db, err = database.New(dbFile, config.Servers.Home)
+	}
 	if err != nil {
 		log.Fatal().Err(err).Msg("failed to create database")
 	}
 
-	// create components
-	var comps []lib.Component
-	{
-		commandHandler, err := components.NewCommands(commands.All)
+	// record gateway events
+	if *record != "" {
+		comps = append(comps, components.NewRecorder(*record))
+	}
+
+	// run commands from stdin
+	if *consoleMode {
+		userID := *consoleUser
+		if userID == "" && len(config.Users.Owners) != 0 {
+			userID = config.Users.Owners[0]
+		}
+		if userID == "" {
+			log.Fatal().Msg("no user to run console commands as - set -console-user or configure an owner")
+		}
+
+		c, err := console.New(comps, config, db, log.Logger, logs, userID, os.Stdout)
 		if err != nil {
-			log.Fatal().Err(err).Msg("failed to create command handler")
+			log.Fatal().Err(err).Msg("failed to start console")
 		}
 
-		readyLogger := components.NewReady()
+		err = c.Run(os.Stdin)
+		c.Close()
+		if err != nil {
+			log.Fatal().Err(err).Msg("failed to read commands")
+		}
 
-		comps = []lib.Component{commandHandler, readyLogger}
+		return
 	}
 
 	// create and run bot
 	{
-		bot := lib.NewBot(comps, config, db, log.Logger)
+		bot := lib.NewBot(comps, config, db, log.Logger, logs)
+		bot.LogLevel = logLevel
 
-		err = bot.Run()
+		err = bot.Run(configFile)
 		if err != nil {
 			log.Fatal().Err(err).Msg("failed to run bot")
 		}
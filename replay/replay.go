@@ -0,0 +1,144 @@
+// Package replay feeds gateway events recorded by components.Recorder back through the bot, against a fake discord
+// and a scratch database, so problems seen in production can be reproduced offline.
+//
+// Events are dispatched one at a time in the order they were recorded, without waiting between them, and the fake
+// discord's state is updated from each event before it's dispatched - so the same recording always produces the same
+// result. What the bot sends is printed along with the time of the event it was responding to. Messages the bot sent
+// while recording aren't replayed, since it sends them again.
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/database"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/components"
+	"github.com/danvolchek/bouncer-go/lib/fakediscord"
+	"github.com/rs/zerolog"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxLineSize is the largest recorded event that can be read. Guild create events for big servers are large.
+const maxLineSize = 64 * 1024 * 1024
+
+// eventTypes maps gateway event types to the discordgo events they're decoded into. Events of other types aren't
+// replayed. discordgo doesn't export its own mapping, so this lists the ones the bot's state or components care about.
+var eventTypes = map[string]func() interface{}{
+	"READY":                   func() interface{} { return &discordgo.Ready{} },
+	"GUILD_CREATE":            func() interface{} { return &discordgo.GuildCreate{} },
+	"GUILD_UPDATE":            func() interface{} { return &discordgo.GuildUpdate{} },
+	"GUILD_DELETE":            func() interface{} { return &discordgo.GuildDelete{} },
+	"GUILD_MEMBER_ADD":        func() interface{} { return &discordgo.GuildMemberAdd{} },
+	"GUILD_MEMBER_UPDATE":     func() interface{} { return &discordgo.GuildMemberUpdate{} },
+	"GUILD_MEMBER_REMOVE":     func() interface{} { return &discordgo.GuildMemberRemove{} },
+	"GUILD_ROLE_CREATE":       func() interface{} { return &discordgo.GuildRoleCreate{} },
+	"GUILD_ROLE_UPDATE":       func() interface{} { return &discordgo.GuildRoleUpdate{} },
+	"GUILD_ROLE_DELETE":       func() interface{} { return &discordgo.GuildRoleDelete{} },
+	"GUILD_BAN_ADD":           func() interface{} { return &discordgo.GuildBanAdd{} },
+	"GUILD_BAN_REMOVE":        func() interface{} { return &discordgo.GuildBanRemove{} },
+	"CHANNEL_CREATE":          func() interface{} { return &discordgo.ChannelCreate{} },
+	"CHANNEL_UPDATE":          func() interface{} { return &discordgo.ChannelUpdate{} },
+	"CHANNEL_DELETE":          func() interface{} { return &discordgo.ChannelDelete{} },
+	"THREAD_CREATE":           func() interface{} { return &discordgo.ThreadCreate{} },
+	"THREAD_UPDATE":           func() interface{} { return &discordgo.ThreadUpdate{} },
+	"THREAD_DELETE":           func() interface{} { return &discordgo.ThreadDelete{} },
+	"MESSAGE_CREATE":          func() interface{} { return &discordgo.MessageCreate{} },
+	"MESSAGE_UPDATE":          func() interface{} { return &discordgo.MessageUpdate{} },
+	"MESSAGE_DELETE":          func() interface{} { return &discordgo.MessageDelete{} },
+	"MESSAGE_DELETE_BULK":     func() interface{} { return &discordgo.MessageDeleteBulk{} },
+	"MESSAGE_REACTION_ADD":    func() interface{} { return &discordgo.MessageReactionAdd{} },
+	"MESSAGE_REACTION_REMOVE": func() interface{} { return &discordgo.MessageReactionRemove{} },
+	"INTERACTION_CREATE":      func() interface{} { return &discordgo.InteractionCreate{} },
+}
+
+// Run replays the events recorded in the file through the components, printing what the bot sends to out.
+// A scratch database is created for the replay and removed afterwards.
+func Run(comps []lib.Component, config *lib.Config, log zerolog.Logger, logs *lib.LogBuffer, path string, out io.Writer) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	dir, err := os.MkdirTemp("", "bouncer-replay")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := database.New(filepath.Join(dir, "bouncer.db"), config.Servers.Home)
+	if err != nil {
+		return err
+	}
+	// closed before the directory is removed, since the file can't be removed while it's open on windows
+	defer database.Close(db)
+
+	// the config is checked when the bot starts, before any recorded state is applied
+	fake := fakediscord.New("bouncer")
+	fake.AddConfigured(config)
+
+	bot := lib.NewBot(comps, config, db, log, logs)
+	err = bot.Start(fake)
+	if err != nil {
+		return err
+	}
+	defer bot.Stop()
+
+	lines := bufio.NewScanner(file)
+	lines.Buffer(nil, maxLineSize)
+
+	replayed, skipped := 0, 0
+	for lineNum := 1; lines.Scan(); lineNum++ {
+		var recorded components.RecordedEvent
+		err = json.Unmarshal(lines.Bytes(), &recorded)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		newEvent, ok := eventTypes[recorded.Type]
+		if !ok {
+			skipped++
+			continue
+		}
+
+		event := newEvent()
+		err = json.Unmarshal(recorded.Data, event)
+		if err != nil {
+			return fmt.Errorf("line %d: failed to decode %s: %w", lineNum, recorded.Type, err)
+		}
+
+		if ownMessage(fake, event) {
+			skipped++
+			continue
+		}
+
+		seen := len(fake.Sent())
+
+		fake.Apply(event)
+		fake.Dispatch(event)
+		replayed++
+
+		for _, message := range fake.Sent()[seen:] {
+			_, _ = fmt.Fprintf(out, "%s [%s] %s\n", recorded.Time.Format(time.DateTime), fake.ChannelName(message.ChannelID), fakediscord.Describe(message))
+		}
+	}
+
+	if err = lines.Err(); err != nil {
+		return err
+	}
+
+	log.Info().Int("replayed", replayed).Int("skipped", skipped).Msg("finished replaying events")
+	return nil
+}
+
+// ownMessage returns whether the event is a message being created by the bot. The bot sends those messages again when
+// it handles the events that caused them, so replaying them as well would show each one twice.
+func ownMessage(fake *fakediscord.Discord, event interface{}) bool {
+	create, ok := event.(*discordgo.MessageCreate)
+	return ok && create.Author != nil && create.Author.ID == fake.BotUser().ID
+}
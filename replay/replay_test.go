@@ -0,0 +1,133 @@
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/bwmarrin/discordgo"
+	"github.com/danvolchek/bouncer-go/commands"
+	"github.com/danvolchek/bouncer-go/lib"
+	"github.com/danvolchek/bouncer-go/lib/components"
+	"github.com/danvolchek/bouncer-go/lib/fakediscord"
+	"github.com/rs/zerolog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOwnMessage(t *testing.T) {
+	fake := fakediscord.New("bouncer")
+
+	// the recorded bot user replaces the fake's once the ready event is applied
+	bot := &discordgo.User{ID: "1", Username: "recorded", Bot: true}
+	fake.Apply(&discordgo.Ready{User: bot})
+
+	user := &discordgo.User{ID: "2", Username: "user"}
+
+	tests := []struct {
+		name  string
+		event interface{}
+		want  bool
+	}{
+		{"bot message", &discordgo.MessageCreate{Message: &discordgo.Message{Author: bot}}, true},
+		{"user message", &discordgo.MessageCreate{Message: &discordgo.Message{Author: user}}, false},
+		{"no author", &discordgo.MessageCreate{Message: &discordgo.Message{}}, false},
+		{"bot edit", &discordgo.MessageUpdate{Message: &discordgo.Message{Author: bot}}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := ownMessage(fake, test.event); got != test.want {
+				t.Errorf("ownMessage = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// recording writes the events to a recording file, as components.Recorder would, and returns its path.
+func recording(t *testing.T, events ...components.RecordedEvent) string {
+	t.Helper()
+
+	var out bytes.Buffer
+	encoder := json.NewEncoder(&out)
+	for _, event := range events {
+		if err := encoder.Encode(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// recorded returns the event as recorded at the time.
+func recorded(t *testing.T, at time.Time, eventType string, data interface{}) components.RecordedEvent {
+	t.Helper()
+
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return components.RecordedEvent{Time: at, Type: eventType, Data: encoded}
+}
+
+func TestRun(t *testing.T) {
+	config := &lib.Config{
+		Prefix:  "$",
+		Servers: lib.ServerConfig{Home: "100"},
+		Users:   lib.UserConfig{Owners: []string{"2"}},
+		GuildConfig: lib.GuildConfig{
+			Categories: lib.CategoryConfig{CommandsEnabled: []string{"300"}},
+			Roles:      lib.RoleConfig{Admin: []string{"400"}},
+		},
+	}
+
+	bot := &discordgo.User{ID: "1", Username: "bouncer", Bot: true}
+	staff := &discordgo.User{ID: "2", Username: "staff"}
+	guild := &discordgo.Guild{
+		ID:      "100",
+		Name:    "home",
+		OwnerID: bot.ID,
+		Members: []*discordgo.Member{{User: bot}, {User: staff, Roles: []string{"400"}}},
+		Roles:   []*discordgo.Role{{ID: "400", Name: "admin"}},
+		Channels: []*discordgo.Channel{
+			{ID: "300", Name: "staff", Type: discordgo.ChannelTypeGuildCategory},
+			{ID: "301", Name: "bot-commands", Type: discordgo.ChannelTypeGuildText, ParentID: "300"},
+			{ID: "302", Name: "general", Type: discordgo.ChannelTypeGuildText},
+		},
+	}
+
+	at := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	path := recording(t,
+		recorded(t, at, "READY", &discordgo.Ready{User: bot, Guilds: []*discordgo.Guild{{ID: guild.ID, Unavailable: true}}}),
+		recorded(t, at, "GUILD_CREATE", &discordgo.GuildCreate{Guild: guild}),
+		recorded(t, at, "MESSAGE_CREATE", &discordgo.MessageCreate{Message: &discordgo.Message{
+			ID: "500", ChannelID: "301", GuildID: guild.ID, Author: staff, Content: "$say <#302> hi",
+		}}),
+		// what the bot sent in response while recording
+		recorded(t, at, "MESSAGE_CREATE", &discordgo.MessageCreate{Message: &discordgo.Message{
+			ID: "501", ChannelID: "302", GuildID: guild.ID, Author: bot, Content: "hi",
+		}}),
+		recorded(t, at, "TYPING_START", map[string]string{}),
+	)
+
+	comps, err := components.All(commands.All, components.NewSysLog())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	err = Run(comps, config, zerolog.Nop(), lib.NewLogBuffer(10), path, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "2023-07-01 12:00:00 [#general] hi\n"; out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}